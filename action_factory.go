@@ -0,0 +1,149 @@
+package cc
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ActionFactory constructs the runner for a single payload action.
+// Factories are called once per action each time the actions are run and
+// may return an error when the runner cannot be constructed from the action.
+type ActionFactory func(pm *PluginManager, action Action) (ActionRunner, error)
+
+// ActionFactoryRegistry holds the action factories registered with RegisterActionFactory.
+// Factories take precedence over runners registered in the ActionRegistry.
+var ActionFactoryRegistry = make(map[string]ActionFactory)
+
+// RegisterActionFactory registers a factory used to construct the runner for actionName.
+func RegisterActionFactory(actionName string, factory ActionFactory) {
+	ActionFactoryRegistry[actionName] = factory
+}
+
+// ActionRunnerType is satisfied by a pointer to a struct that embeds ActionRunnerBase
+// and implements ActionRunner.
+type ActionRunnerType[T any] interface {
+	*T
+	ActionRunner
+	bind(pm *PluginManager, action Action)
+}
+
+// RegisterActionType registers T as the runner for actionName without relying on reflection.
+// T must embed ActionRunnerBase and *T must implement ActionRunner, which is checked at compile time:
+//
+//	cc.RegisterActionType[MyAction]("my-action")
+func RegisterActionType[T any, PT ActionRunnerType[T]](actionName string) {
+	RegisterActionFactory(actionName, func(pm *PluginManager, action Action) (ActionRunner, error) {
+		runner := PT(new(T))
+		runner.bind(pm, action)
+		return runner, nil
+	})
+}
+
+// bind sets the plugin manager and action on the runner base
+func (arb *ActionRunnerBase) bind(pm *PluginManager, action Action) {
+	arb.PluginManager = pm
+	arb.Action = action
+	arb.ActionName = action.Name
+}
+
+func (arb *ActionRunnerBase) continueOnError() bool {
+	return arb.ContinueOnError
+}
+
+type actionBinder interface {
+	bind(pm *PluginManager, action Action)
+}
+
+type continueOnErrorRunner interface {
+	continueOnError() bool
+}
+
+// newActionRunner creates the runner for an action.
+// The boolean result is false when no runner is registered for the action name.
+func (pm *PluginManager) newActionRunner(action Action) (ActionRunner, bool, error) {
+	if factory, ok := ActionFactoryRegistry[action.Name]; ok {
+		runner, err := factory(pm, action)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to create runner for action %s: %w", action.Name, err)
+		}
+		if runner == nil {
+			return nil, true, fmt.Errorf("factory for action %s returned a nil runner", action.Name)
+		}
+		return runner, true, nil
+	}
+	if prototype, ok := ActionRegistry[action.Name]; ok {
+		runner, err := newReflectedActionRunner(pm, action, prototype)
+		return runner, true, err
+	}
+	return nil, false, nil
+}
+
+// newReflectedActionRunner creates a new instance of the prototype runner registered in the ActionRegistry.
+// Runners that embed ActionRunnerBase are bound directly.  Other runners must be struct pointers
+// with assignable PluginManager, Action and ActionName fields.
+func newReflectedActionRunner(pm *PluginManager, action Action, prototype ActionRunner) (ActionRunner, error) {
+	t := reflect.TypeOf(prototype)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("runner registered for action %s must be a pointer to a struct, found %T", action.Name, prototype)
+	}
+	pointerVal := reflect.New(t.Elem()) //create a new struct instance from the prototype type
+	runner, ok := pointerVal.Interface().(ActionRunner)
+	if !ok {
+		return nil, fmt.Errorf("runner registered for action %s does not implement ActionRunner", action.Name)
+	}
+	if binder, ok := runner.(actionBinder); ok {
+		binder.bind(pm, action)
+		return runner, nil
+	}
+
+	structVal := pointerVal.Elem()
+	fields := []struct {
+		name string
+		val  reflect.Value
+	}{
+		{"PluginManager", reflect.ValueOf(pm)},
+		{"Action", reflect.ValueOf(action)},
+		{"ActionName", reflect.ValueOf(action.Name)},
+	}
+	for _, f := range fields {
+		field := structVal.FieldByName(f.name)
+		if !field.IsValid() || !field.CanSet() || !f.val.Type().AssignableTo(field.Type()) {
+			return nil, fmt.Errorf("runner registered for action %s must embed ActionRunnerBase or declare a %s field of type %s", action.Name, f.name, f.val.Type())
+		}
+		field.Set(f.val)
+	}
+	return runner, nil
+}
+
+// RegisteredActionNames returns the sorted names of all actions registered
+// in the ActionFactoryRegistry and the ActionRegistry
+func RegisteredActionNames() []string {
+	names := []string{}
+	for name := range ActionFactoryRegistry {
+		names = append(names, name)
+	}
+	for name := range ActionRegistry {
+		if _, ok := ActionFactoryRegistry[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// checkUnregisteredActions returns an error listing every action without a runner
+func checkUnregisteredActions(actions []Action, runners []ActionRunner) error {
+	unknown := []string{}
+	for i, runner := range runners {
+		if runner == nil && !slices.Contains(unknown, actions[i].Name) {
+			unknown = append(unknown, actions[i].Name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("payload contains unregistered actions [%s]. registered actions are [%s]",
+			strings.Join(unknown, ", "), strings.Join(RegisteredActionNames(), ", "))
+	}
+	return nil
+}
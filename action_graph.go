@@ -0,0 +1,215 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type actionState int8
+
+const (
+	actionPending actionState = iota
+	actionRunning
+	actionDone
+	actionFailed
+	actionSkipped
+)
+
+type actionNode struct {
+	action     Action
+	deps       []int
+	dependents []int
+}
+
+// actionGraph is the dependency graph of the payload actions.
+// Nodes are identified by their index in the payload action list.
+type actionGraph struct {
+	nodes []actionNode
+}
+
+type actionOutcome struct {
+	index int
+	err   error
+}
+
+// newActionGraph builds the dependency graph for a list of actions.
+//
+// Dependencies are declared by name in the action "depends_on" list.  A dependency on a name
+// shared by several actions depends on all of them.  When no action declares a dependency
+// the actions are chained in payload order, which preserves sequential execution.
+func newActionGraph(actions []Action) (*actionGraph, error) {
+	graph := actionGraph{nodes: make([]actionNode, len(actions))}
+	byName := make(map[string][]int)
+	hasDeps := false
+	for i, action := range actions {
+		graph.nodes[i].action = action
+		byName[action.Name] = append(byName[action.Name], i)
+		if len(action.DependsOn) > 0 {
+			hasDeps = true
+		}
+	}
+
+	for i, action := range actions {
+		if !hasDeps {
+			if i > 0 {
+				graph.addEdge(i-1, i)
+			}
+			continue
+		}
+		for _, depName := range action.DependsOn {
+			depIndexes, ok := byName[depName]
+			if !ok {
+				return nil, fmt.Errorf("action %s depends on unknown action %s", action.Name, depName)
+			}
+			for _, d := range depIndexes {
+				graph.addEdge(d, i)
+			}
+		}
+	}
+
+	if cycle := graph.findCycle(); cycle != nil {
+		names := make([]string, len(cycle))
+		for i, n := range cycle {
+			names[i] = graph.nodes[n].action.Name
+		}
+		return nil, fmt.Errorf("action dependency cycle: %s", strings.Join(names, " -> "))
+	}
+	return &graph, nil
+}
+
+func (g *actionGraph) addEdge(from int, to int) {
+	g.nodes[to].deps = append(g.nodes[to].deps, from)
+	g.nodes[from].dependents = append(g.nodes[from].dependents, to)
+}
+
+// findCycle returns the node indexes of a dependency cycle, or nil if the graph is acyclic
+func (g *actionGraph) findCycle() []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(g.nodes))
+	stack := []int{}
+	var visit func(n int) []int
+	visit = func(n int) []int {
+		marks[n] = visiting
+		stack = append(stack, n)
+		for _, d := range g.nodes[n].dependents {
+			switch marks[d] {
+			case visiting:
+				for i, s := range stack {
+					if s == d {
+						return append(append([]int{}, stack[i:]...), d)
+					}
+				}
+			case unvisited:
+				if cycle := visit(d); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		marks[n] = visited
+		return nil
+	}
+	for n := range g.nodes {
+		if marks[n] == unvisited {
+			if cycle := visit(n); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// run executes the graph running at most parallelism actions at a time.
+// Ready actions are started in payload order.  When an action fails, every action that
+// depends on it (directly or indirectly) is skipped while independent actions continue.
+// Once ctx is cancelled no new actions are started and the running actions are awaited.
+// The final state of each action is returned with the joined action errors.
+func (g *actionGraph) run(ctx context.Context, parallelism int, logger *CcLogger, runFn func(ctx context.Context, index int, action Action) error) ([]actionState, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	states := make([]actionState, len(g.nodes))
+	remaining := make([]int, len(g.nodes))
+	ready := []int{}
+	for i, node := range g.nodes {
+		remaining[i] = len(node.deps)
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	results := make(chan actionOutcome)
+	running := 0
+	var errs []error
+	for {
+		for len(ready) > 0 && running < parallelism && ctx.Err() == nil {
+			i := ready[0]
+			ready = ready[1:]
+			states[i] = actionRunning
+			running++
+			go func(i int) {
+				results <- actionOutcome{i, runFn(ctx, i, g.nodes[i].action)}
+			}(i)
+		}
+		if running == 0 {
+			break
+		}
+
+		outcome := <-results
+		running--
+		if outcome.err != nil {
+			states[outcome.index] = actionFailed
+			errs = append(errs, outcome.err)
+			for _, skipped := range g.skipDependents(outcome.index, states) {
+				logger.Warn("action skipped", "action", g.nodes[skipped].action.Name, "failed_dependency", g.nodes[outcome.index].action.Name)
+			}
+			continue
+		}
+		states[outcome.index] = actionDone
+		for _, d := range g.nodes[outcome.index].dependents {
+			remaining[d]--
+			if remaining[d] == 0 && states[d] == actionPending {
+				ready = append(ready, d)
+			}
+		}
+		sort.Ints(ready)
+	}
+
+	if ctx.Err() != nil {
+		notStarted := []string{}
+		for i, state := range states {
+			if state == actionPending {
+				notStarted = append(notStarted, g.nodes[i].action.Name)
+			}
+		}
+		if len(notStarted) > 0 {
+			errs = append(errs, fmt.Errorf("%w: %s not started: %s", ErrActionInterrupted, strings.Join(notStarted, ", "), context.Cause(ctx)))
+		}
+	}
+	return states, errors.Join(errs...)
+}
+
+// skipDependents marks all pending transitive dependents of a failed node as skipped
+// and returns their indexes
+func (g *actionGraph) skipDependents(failed int, states []actionState) []int {
+	skipped := []int{}
+	queue := append([]int{}, g.nodes[failed].dependents...)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if states[n] != actionPending {
+			continue
+		}
+		states[n] = actionSkipped
+		skipped = append(skipped, n)
+		queue = append(queue, g.nodes[n].dependents...)
+	}
+	return skipped
+}
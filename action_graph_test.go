@@ -0,0 +1,97 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestActionGraphCycle(t *testing.T) {
+	_, err := newActionGraph([]Action{
+		{Name: "a", DependsOn: []string{"c"}},
+		{Name: "b", DependsOn: []string{"a"}},
+		{Name: "c", DependsOn: []string{"b"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle error, found %v", err)
+	}
+}
+
+func TestActionGraphUnknownDependency(t *testing.T) {
+	_, err := newActionGraph([]Action{
+		{Name: "a", DependsOn: []string{"missing"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected an unknown dependency error, found %v", err)
+	}
+}
+
+func TestActionGraphRun(t *testing.T) {
+	graph, err := newActionGraph([]Action{
+		{Name: "unzip1"},
+		{Name: "unzip2"},
+		{Name: "compute", DependsOn: []string{"unzip1", "unzip2"}},
+		{Name: "post1", DependsOn: []string{"compute"}},
+		{Name: "post2", DependsOn: []string{"compute"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	completed := map[string]bool{}
+	logger := NewCcLogger(CcLoggerInput{})
+	_, err = graph.run(context.Background(), 3, logger, func(ctx context.Context, i int, action Action) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, dep := range action.DependsOn {
+			if !completed[dep] {
+				t.Errorf("%s started before its dependency %s completed", action.Name, dep)
+			}
+		}
+		completed[action.Name] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 5 {
+		t.Fatalf("expected 5 completed actions, found %v", completed)
+	}
+}
+
+func TestActionGraphFailurePropagation(t *testing.T) {
+	graph, err := newActionGraph([]Action{
+		{Name: "stage"},
+		{Name: "independent"},
+		{Name: "compute", DependsOn: []string{"stage"}},
+		{Name: "post", DependsOn: []string{"compute"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	ran := map[string]bool{}
+	stageErr := errors.New("stage failed")
+	_, err = graph.run(context.Background(), 2, NewCcLogger(CcLoggerInput{}), func(ctx context.Context, i int, action Action) error {
+		mu.Lock()
+		ran[action.Name] = true
+		mu.Unlock()
+		if action.Name == "stage" {
+			return stageErr
+		}
+		return nil
+	})
+	if !errors.Is(err, stageErr) {
+		t.Fatalf("expected the stage error, found %v", err)
+	}
+	if ran["compute"] || ran["post"] {
+		t.Fatalf("dependents of a failed action should not run: %v", ran)
+	}
+	if !ran["independent"] {
+		t.Fatal("independent action should run")
+	}
+}
@@ -0,0 +1,160 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ActionPhase identifies the lifecycle phase of an action
+type ActionPhase string
+
+const (
+	PhaseCreate   ActionPhase = "create"
+	PhaseValidate ActionPhase = "validate"
+	PhaseSetup    ActionPhase = "setup"
+	PhaseRun      ActionPhase = "run"
+	PhaseTeardown ActionPhase = "teardown"
+)
+
+// ActionValidator is implemented by runners that check their action before any action runs.
+// Validation errors from every action are reported together and no action is run.
+type ActionValidator interface {
+	Validate() error
+}
+
+// ActionSetup is implemented by runners that stage data or resources before Run
+type ActionSetup interface {
+	Setup() error
+}
+
+// ActionTeardown is implemented by runners that release resources after Run.
+// Teardown is called whenever Setup was called, even if Setup or Run failed.
+type ActionTeardown interface {
+	Teardown() error
+}
+
+// ActionError is an error raised in a specific lifecycle phase of an action
+type ActionError struct {
+	Action string
+	Phase  ActionPhase
+	Err    error
+}
+
+func (ae *ActionError) Error() string {
+	return fmt.Sprintf("action %s %s error: %s", ae.Action, ae.Phase, ae.Err)
+}
+
+func (ae *ActionError) Unwrap() error {
+	return ae.Err
+}
+
+// newActionRunners creates the runners for every action in the payload.
+// Actions without a registered runner have a nil entry.
+func (pm *PluginManager) newActionRunners(actions []Action) ([]ActionRunner, error) {
+	runners := make([]ActionRunner, len(actions))
+	var errs []error
+	for i, action := range actions {
+		runner, ok, err := pm.newActionRunner(action)
+		if err != nil {
+			errs = append(errs, &ActionError{action.Name, PhaseCreate, err})
+			continue
+		}
+		if ok {
+			runners[i] = runner
+		}
+	}
+	return runners, errors.Join(errs...)
+}
+
+// validateActionRunners validates the attributes of every runner that implements AttributeSchemaDeclarer,
+// calls Validate on every runner that implements ActionValidator and returns all of the validation errors
+func validateActionRunners(actions []Action, runners []ActionRunner) error {
+	var errs []error
+	for i, runner := range runners {
+		if err := validateActionRunner(i, actions[i], runner); err != nil {
+			errs = append(errs, &ActionError{actions[i].Name, PhaseValidate, err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateActionRunner checks the attribute schema and then calls Validate for a single runner
+func validateActionRunner(index int, action Action, runner ActionRunner) error {
+	if err := validateAttributeSchema(index, action, runner); err != nil {
+		return err
+	}
+	if validator, ok := runner.(ActionValidator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// executeAction runs the Setup, Run and Teardown phases of a runner.
+// The action context, including the action timeout, is set before Setup so runners can watch it with Context().
+// Teardown gets its own context, see teardownContext.  Teardown is called whenever Setup was attempted, after Run has returned, and errors from each phase are reported separately.
+// Resource usage is sampled from Setup through Teardown and the action workspace is released after Teardown.
+func (pm *PluginManager) executeAction(ctx context.Context, action Action, runner ActionRunner) (ActionResult, error) {
+	var errs []error
+	var result ActionResult
+	ws := pm.newWorkspace(action)
+	if wss, ok := runner.(workspaceSetter); ok {
+		wss.setWorkspace(ws)
+	}
+	monitor := pm.startResourceMonitor(action, ws)
+	setupErr := error(nil)
+	if setup, ok := runner.(ActionSetup); ok {
+		setupCtx, cancel, err := actionContext(ctx, action)
+		if cs, ok := runner.(contextSetter); ok {
+			cs.setContext(setupCtx)
+		}
+		if err == nil {
+			err = setup.Setup()
+		}
+		cancel()
+		if err != nil {
+			setupErr = &ActionError{action.Name, PhaseSetup, err}
+			errs = append(errs, setupErr)
+		}
+	}
+
+	if setupErr == nil {
+		var err error
+		result, err = pm.runActionWithRetry(ctx, action, runner)
+		if err != nil {
+			errs = append(errs, &ActionError{action.Name, PhaseRun, err})
+		}
+	}
+
+	if teardown, ok := runner.(ActionTeardown); ok {
+		teardownCtx, cancel, err := pm.teardownContext(ctx, action)
+		if cs, ok := runner.(contextSetter); ok {
+			cs.setContext(teardownCtx)
+		}
+		if err == nil {
+			err = teardown.Teardown()
+		}
+		cancel()
+		if err != nil {
+			errs = append(errs, &ActionError{action.Name, PhaseTeardown, err})
+		}
+	}
+	result.Resources = monitor.finish()
+	result.Workspace = pm.releaseWorkspace(ws, len(errs) > 0)
+	return result, errors.Join(errs...)
+}
+
+// teardownContext is the context set before Teardown.  It is not cancelled with ctx, so a runner can release
+// its resources after a timeout or an interrupt, but it is bounded by the action timeout and, once ctx is done,
+// by the shutdown grace period.
+func (pm *PluginManager) teardownContext(ctx context.Context, action Action) (context.Context, context.CancelFunc, error) {
+	teardownCtx, cancel, err := actionContext(context.WithoutCancel(ctx), action)
+	if err != nil || ctx.Err() == nil {
+		return teardownCtx, cancel, err
+	}
+	teardownCtx, graceCancel := context.WithTimeout(teardownCtx, pm.shutdownGracePeriod())
+	return teardownCtx, func() {
+		graceCancel()
+		cancel()
+	}, nil
+}
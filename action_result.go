@@ -0,0 +1,137 @@
+package cc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const runSummaryFileName = "run-summary"
+
+// ActionStatus is the final state of an action in a run
+type ActionStatus string
+
+const (
+	ActionSucceeded   ActionStatus = "succeeded"
+	ActionFailed      ActionStatus = "failed"
+	ActionSkipped     ActionStatus = "skipped"
+	ActionInterrupted ActionStatus = "interrupted"
+)
+
+// OutputReference identifies a resource written by an action
+type OutputReference struct {
+	DataSource string `json:"data_source,omitempty"`
+	StoreName  string `json:"store_name,omitempty"`
+	PathKey    string `json:"path_key,omitempty"`
+	Path       string `json:"path,omitempty"`
+}
+
+// ActionResult is the record of a single action in a run.
+// Runners that implement ResultActionRunner provide the Outputs and Results.
+// The plugin manager fills in the action name, status, timings, attempts and error.
+type ActionResult struct {
+	Action          string            `json:"action"`
+	Status          ActionStatus      `json:"status"`
+	Start           *time.Time        `json:"start,omitempty"` //nil for actions that did not run
+	End             *time.Time        `json:"end,omitempty"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Attempts        int               `json:"attempts,omitempty"`
+	Checkpointed    bool              `json:"checkpointed,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	Workspace       string            `json:"workspace,omitempty"` //set when the action workspace was kept
+	Resources       *ResourceUsage    `json:"resources,omitempty"`
+	Outputs         []OutputReference `json:"outputs,omitempty"`
+	Results         map[string]any    `json:"results,omitempty"`
+}
+
+// ResultActionRunner is implemented by runners that return a structured result.
+// When a runner implements ResultActionRunner, RunResult is called in place of Run and RunContext.
+type ResultActionRunner interface {
+	RunResult(ctx context.Context) (ActionResult, error)
+}
+
+// RunSummary is the record of a RunActions call.
+// It is written to the CcStore under the manifest as run-summary-<event identifier>.json.
+type RunSummary struct {
+	Manifest        string         `json:"manifest"`
+	Payload         string         `json:"payload"`
+	EventIdentifier string         `json:"event_identifier,omitempty"`
+	Status          Status         `json:"status"`
+	Start           time.Time      `json:"start"`
+	End             time.Time      `json:"end"`
+	Error           string         `json:"error,omitempty"`
+	Actions         []ActionResult `json:"actions"`
+}
+
+// RunSummary returns the summary of the most recent RunActions call
+func (pm *PluginManager) RunSummary() RunSummary {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.summary
+}
+
+// completeResult fills the manager controlled fields of an action result
+func completeResult(result *ActionResult, action Action, start time.Time, err error) {
+	result.Action = action.Name
+	end := time.Now()
+	result.Start = &start
+	result.End = &end
+	result.DurationSeconds = end.Sub(start).Seconds()
+	switch {
+	case err == nil:
+		if result.Status == "" {
+			result.Status = ActionSucceeded
+		}
+	case errors.Is(err, ErrActionInterrupted):
+		result.Status = ActionInterrupted
+		result.Error = err.Error()
+	default:
+		result.Status = ActionFailed
+		result.Error = err.Error()
+	}
+}
+
+// skippedResults fills the results of the actions that did not run
+func skippedResults(results []ActionResult, actions []Action, states []actionState, runErr error) {
+	for i, state := range states {
+		if results[i].Status != "" {
+			continue
+		}
+		results[i].Action = actions[i].Name
+		results[i].Status = ActionSkipped
+		switch state {
+		case actionSkipped:
+			results[i].Error = "a dependency failed"
+		case actionPending:
+			if errors.Is(runErr, ErrActionInterrupted) {
+				results[i].Error = "not started: run interrupted"
+			}
+		}
+	}
+}
+
+// writeRunSummary persists the run summary to the CcStore.
+// Failing to write the summary is logged and does not fail the run.
+func (pm *PluginManager) writeRunSummary(summary RunSummary) {
+	if pm.ccStore == nil {
+		return
+	}
+	data, err := json.Marshal(summary)
+	if err == nil {
+		fileName := runSummaryFileName
+		if summary.EventIdentifier != "" {
+			fileName = fmt.Sprintf("%s-%s", runSummaryFileName, summary.EventIdentifier)
+		}
+		err = pm.ccStore.PutObject(PutObjectInput{
+			FileName:      fileName,
+			FileExtension: "json",
+			ObjectState:   Memory,
+			Data:          data,
+		})
+	}
+	if err != nil {
+		pm.Logger.Error("failed to write the run summary", "error", err.Error())
+	}
+}
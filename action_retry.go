@@ -0,0 +1,206 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMaxBackoff     = time.Minute
+)
+
+// error classes used to select retryable errors in a RetryPolicy
+const (
+	ErrorClassTransient = "transient"
+	ErrorClassTimeout   = "timeout"
+	ErrorClassNetwork   = "network"
+	ErrorClassUnknown   = "unknown"
+)
+
+// RetryPolicy declares how a failed action is retried.
+// Backoff values are duration strings (for example "5s" or "2m").
+// The backoff doubles after each failed attempt up to MaxBackoff.
+// When RetryOn is empty every error class is retried.
+type RetryPolicy struct {
+	MaxAttempts    int      `json:"max_attempts,omitempty"`
+	InitialBackoff string   `json:"initial_backoff,omitempty"`
+	MaxBackoff     string   `json:"max_backoff,omitempty"`
+	RetryOn        []string `json:"retry_on,omitempty"`
+}
+
+// ClassifiedError attaches an error class to an error so that runners can
+// mark failures as retryable (for example ErrorClassTransient)
+type ClassifiedError struct {
+	Class string
+	Err   error
+}
+
+func (ce *ClassifiedError) Error() string {
+	return ce.Err.Error()
+}
+
+func (ce *ClassifiedError) Unwrap() error {
+	return ce.Err
+}
+
+func (ce *ClassifiedError) ErrorClass() string {
+	return ce.Class
+}
+
+// ClassifyError wraps err with an error class
+func ClassifyError(class string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{class, err}
+}
+
+// ErrorClassOf returns the class of an error.
+// Errors carrying an ErrorClass() method report their own class, deadline errors are
+// classified as timeouts and net.Error values as network errors.
+func ErrorClassOf(err error) string {
+	var classified interface{ ErrorClass() string }
+	if errors.As(err, &classified) {
+		return classified.ErrorClass()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+	return ErrorClassUnknown
+}
+
+// RetryError is returned when every attempt of an action fails.
+// It wraps the error of each attempt.
+type RetryError struct {
+	Action   string
+	Attempts []error
+}
+
+func (re *RetryError) Error() string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("action %s failed after %d attempts", re.Action, len(re.Attempts)))
+	for i, err := range re.Attempts {
+		builder.WriteString(fmt.Sprintf("; attempt %d: %s", i+1, err))
+	}
+	return builder.String()
+}
+
+func (re *RetryError) Unwrap() []error {
+	return re.Attempts
+}
+
+// actionRetryPolicy is the resolved retry policy of an action
+type actionRetryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	retryOn        []string
+}
+
+// retryPolicy resolves the retry policy of an action using the PluginManagerConfig
+// retry settings as defaults for any value not declared in the payload
+func (pm *PluginManager) retryPolicy(action Action) (actionRetryPolicy, error) {
+	policy := actionRetryPolicy{
+		maxAttempts:    pm.config.MaxRetry + 1,
+		initialBackoff: pm.config.RetryInitialBackoff,
+		maxBackoff:     pm.config.RetryMaxBackoff,
+	}
+	if policy.initialBackoff <= 0 {
+		policy.initialBackoff = defaultRetryInitialBackoff
+	}
+	if policy.maxBackoff <= 0 {
+		policy.maxBackoff = defaultRetryMaxBackoff
+	}
+
+	if rp := action.Retry; rp != nil {
+		if rp.MaxAttempts > 0 {
+			policy.maxAttempts = rp.MaxAttempts
+		}
+		if rp.InitialBackoff != "" {
+			d, err := time.ParseDuration(rp.InitialBackoff)
+			if err != nil {
+				return policy, fmt.Errorf("invalid retry initial_backoff for action %s: %w", action.Name, err)
+			}
+			policy.initialBackoff = d
+		}
+		if rp.MaxBackoff != "" {
+			d, err := time.ParseDuration(rp.MaxBackoff)
+			if err != nil {
+				return policy, fmt.Errorf("invalid retry max_backoff for action %s: %w", action.Name, err)
+			}
+			policy.maxBackoff = d
+		}
+		policy.retryOn = rp.RetryOn
+	}
+	if policy.maxAttempts < 1 {
+		policy.maxAttempts = 1
+	}
+	return policy, nil
+}
+
+func (p actionRetryPolicy) retryable(err error) bool {
+	if errors.Is(err, ErrInterruptSignal) {
+		return false
+	}
+	return len(p.retryOn) == 0 || slices.Contains(p.retryOn, ErrorClassOf(err))
+}
+
+// runActionWithRetry runs an action, retrying failed attempts according to the action retry policy.
+// Each failed attempt is logged as an action message.  Attempts stop when ctx is cancelled.
+// An interrupted attempt of a plain runner is not retried because its Run call completed after the
+// interruption (see runAction).
+func (pm *PluginManager) runActionWithRetry(ctx context.Context, action Action, runner ActionRunner) (ActionResult, error) {
+	policy, err := pm.retryPolicy(action)
+	if err != nil {
+		return ActionResult{}, err
+	}
+
+	attemptErrs := []error{}
+	backoff := policy.initialBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := pm.runAction(ctx, action, runner)
+		result.Attempts = attempt
+		if err == nil {
+			return result, nil
+		}
+		attemptErrs = append(attemptErrs, err)
+
+		overran := !contextAware(runner) && errors.Is(err, ErrActionInterrupted)
+		if overran || attempt >= policy.maxAttempts || ctx.Err() != nil || !policy.retryable(err) {
+			if len(attemptErrs) == 1 {
+				return result, err
+			}
+			return result, &RetryError{action.Name, attemptErrs}
+		}
+
+		pm.Logger.Action("action attempt failed",
+			"action", action.Name,
+			"attempt", attempt,
+			"max_attempts", policy.maxAttempts,
+			"error_class", ErrorClassOf(err),
+			"retry_in", backoff.String(),
+			"error", err.Error(),
+		)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			attemptErrs = append(attemptErrs, fmt.Errorf("%w: %s: %w", ErrActionInterrupted, action.Name, context.Cause(ctx)))
+			return result, &RetryError{action.Name, attemptErrs}
+		}
+		backoff = min(backoff*2, policy.maxBackoff)
+	}
+}
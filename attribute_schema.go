@@ -0,0 +1,352 @@
+package cc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// AttributeSchemaDeclarer is implemented by runners that declare the attributes of their action.
+// AttributeSchema returns a value of the attribute struct, for example ModelAttributes{}.
+// A JSON Schema is reflected from the struct (json and jsonschema struct tags apply) and the
+// substituted action attributes are validated against it before any action runs.
+//
+// Fields without omitempty are required.  Attributes that are not declared are allowed
+// because the PluginManager also reads attributes such as "cc_timeout".
+//
+// Substitution produces strings, so string attributes declared as a number, integer or boolean
+// are converted to that type before they are validated and decoded.
+//
+// The type, enum, const, allOf, anyOf, oneOf, required, properties, additionalProperties, items,
+// minItems, maxItems, uniqueItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum and multipleOf keywords are validated.  The format keyword
+// is an annotation and is not checked.  A schema that uses any other validation keyword
+// (for example $ref, not or if) is rejected.
+type AttributeSchemaDeclarer interface {
+	AttributeSchema() any
+}
+
+// ReflectAttributeSchema reflects the JSON Schema of an attribute struct
+func ReflectAttributeSchema(attributes any) *jsonschema.Schema {
+	reflector := jsonschema.Reflector{
+		DoNotReference:            true,
+		ExpandedStruct:            true,
+		Anonymous:                 true,
+		AllowAdditionalProperties: true,
+	}
+	schema := reflector.Reflect(attributes)
+	schema.Version = ""
+	return schema
+}
+
+// ValidateAttributes validates attributes against a JSON Schema.
+// All of the violations are returned in a ValidationError with paths relative to path.
+// An error is returned without validating when the schema uses an unsupported keyword.
+func ValidateAttributes(schema *jsonschema.Schema, attributes PayloadAttributes, path string) error {
+	if keywords := unsupportedKeywords(schema, nil); len(keywords) > 0 {
+		return fmt.Errorf("unsupported attribute schema keywords: %s", strings.Join(keywords, ", "))
+	}
+	value, err := normalizeJSON(attributes)
+	if err != nil {
+		return fmt.Errorf("unable to validate attributes: %w", err)
+	}
+	verr := ValidationError{}
+	validateSchema(schema, value, path, &verr)
+	return verr.err()
+}
+
+// DecodeAttributes decodes the action attributes into the attribute struct pointed to by v
+// using the same json tags the schema is reflected from.  String attributes are converted to the
+// number, integer or boolean type of the struct field; the action attributes are not modified.
+func (arb *ActionRunnerBase) DecodeAttributes(v any) error {
+	value, err := normalizeJSON(arb.Action.Attributes)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(coerceAttributes(ReflectAttributeSchema(v), value))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// validateAttributeSchema validates the attributes of an action when its runner declares an attribute schema
+func validateAttributeSchema(index int, action Action, runner ActionRunner) error {
+	declarer, ok := runner.(AttributeSchemaDeclarer)
+	if !ok {
+		return nil
+	}
+	path := jsonPathField(jsonPathIndex("$.actions", index), "attributes")
+	schema := ReflectAttributeSchema(declarer.AttributeSchema())
+	//coerce a copy so Plan and dry runs do not change the payload attributes
+	value, err := normalizeJSON(action.Attributes)
+	if err != nil {
+		return fmt.Errorf("unable to validate attributes: %w", err)
+	}
+	coerced, _ := coerceAttributes(schema, value).(map[string]any)
+	return ValidateAttributes(schema, coerced, path)
+}
+
+// coerceAttributes converts the string values of a decoded JSON value to the number, integer or
+// boolean type declared by the schema.  Maps and slices are updated in place, so callers pass a copy
+// (see normalizeJSON).  Strings that can not be converted are left for validation to report.
+func coerceAttributes(schema *jsonschema.Schema, value any) any {
+	if schema == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case string:
+		switch schema.Type {
+		case "integer":
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return i
+			}
+		case "number":
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	case PayloadAttributes:
+		coerceAttributes(schema, map[string]any(v))
+	case map[string]any:
+		for name, item := range v {
+			v[name] = coerceAttributes(propertySchema(schema, name), item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = coerceAttributes(schema.Items, item)
+		}
+	}
+	return value
+}
+
+// normalizeJSON converts a value into the generic types produced by encoding/json
+func normalizeJSON(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+func validateSchema(schema *jsonschema.Schema, value any, path string, verr *ValidationError) {
+	if schema == nil || schema == jsonschema.TrueSchema {
+		return
+	}
+	if schema == jsonschema.FalseSchema {
+		verr.add(path, "is not allowed")
+		return
+	}
+	if schema.Type != "" && !matchesSchemaType(schema.Type, value) {
+		verr.add(path, "expected %s, found %s", schema.Type, jsonTypeName(value))
+		return
+	}
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(e any) bool { return jsonEqual(e, value) }) {
+		verr.add(path, "must be one of %v, found %v", schema.Enum, value)
+	}
+	if schema.Const != nil && !jsonEqual(schema.Const, value) {
+		verr.add(path, "must be %v, found %v", schema.Const, value)
+	}
+	for _, sub := range schema.AllOf {
+		validateSchema(sub, value, path, verr)
+	}
+	if len(schema.AnyOf) > 0 && countMatches(schema.AnyOf, value) == 0 {
+		verr.add(path, "does not match any of the allowed schemas")
+	}
+	if len(schema.OneOf) > 0 && countMatches(schema.OneOf, value) != 1 {
+		verr.add(path, "must match exactly one of the allowed schemas")
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		validateObject(schema, v, path, verr)
+	case []any:
+		validateArray(schema, v, path, verr)
+	case string:
+		validateString(schema, v, path, verr)
+	case float64:
+		validateNumber(schema, v, path, verr)
+	}
+}
+
+func validateObject(schema *jsonschema.Schema, obj map[string]any, path string, verr *ValidationError) {
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			verr.add(jsonPathField(path, name), "is required")
+		}
+	}
+	for _, name := range sortedKeys(obj) {
+		validateSchema(propertySchema(schema, name), obj[name], jsonPathField(path, name), verr)
+	}
+}
+
+// propertySchema returns the schema of a named property or the additional properties schema
+func propertySchema(schema *jsonschema.Schema, name string) *jsonschema.Schema {
+	var propSchema *jsonschema.Schema
+	if schema.Properties != nil {
+		propSchema, _ = schema.Properties.Get(name)
+	}
+	if propSchema == nil {
+		propSchema = schema.AdditionalProperties
+	}
+	return propSchema
+}
+
+// unsupportedKeywords appends the validation keywords used by a schema and its sub-schemas
+// that validateSchema does not check
+func unsupportedKeywords(schema *jsonschema.Schema, keywords []string) []string {
+	if schema == nil {
+		return keywords
+	}
+	add := func(used bool, keyword string) {
+		if used && !slices.Contains(keywords, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+	add(schema.Ref != "", "$ref")
+	add(schema.DynamicRef != "", "$dynamicRef")
+	add(schema.Not != nil, "not")
+	add(schema.If != nil || schema.Then != nil || schema.Else != nil, "if")
+	add(len(schema.DependentSchemas) > 0, "dependentSchemas")
+	add(len(schema.DependentRequired) > 0, "dependentRequired")
+	add(len(schema.PrefixItems) > 0, "prefixItems")
+	add(schema.Contains != nil || schema.MinContains != nil || schema.MaxContains != nil, "contains")
+	add(len(schema.PatternProperties) > 0, "patternProperties")
+	add(schema.PropertyNames != nil, "propertyNames")
+	add(schema.MinProperties != nil || schema.MaxProperties != nil, "minProperties/maxProperties")
+
+	subschemas := slices.Concat(schema.AllOf, schema.AnyOf, schema.OneOf)
+	subschemas = append(subschemas, schema.Items, schema.AdditionalProperties)
+	if schema.Properties != nil {
+		for pair := schema.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			subschemas = append(subschemas, pair.Value)
+		}
+	}
+	for _, sub := range subschemas {
+		keywords = unsupportedKeywords(sub, keywords)
+	}
+	return keywords
+}
+
+func validateArray(schema *jsonschema.Schema, arr []any, path string, verr *ValidationError) {
+	if schema.MinItems != nil && uint64(len(arr)) < *schema.MinItems {
+		verr.add(path, "must have at least %d items, found %d", *schema.MinItems, len(arr))
+	}
+	if schema.MaxItems != nil && uint64(len(arr)) > *schema.MaxItems {
+		verr.add(path, "must have at most %d items, found %d", *schema.MaxItems, len(arr))
+	}
+	if schema.UniqueItems {
+		for i := range arr {
+			if slices.ContainsFunc(arr[:i], func(e any) bool { return reflect.DeepEqual(e, arr[i]) }) {
+				verr.add(path, "must have unique items, found %v more than once", arr[i])
+				break
+			}
+		}
+	}
+	for i, item := range arr {
+		validateSchema(schema.Items, item, jsonPathIndex(path, i), verr)
+	}
+}
+
+func validateString(schema *jsonschema.Schema, s string, path string, verr *ValidationError) {
+	length := uint64(len([]rune(s)))
+	if schema.MinLength != nil && length < *schema.MinLength {
+		verr.add(path, "must be at least %d characters", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		verr.add(path, "must be at most %d characters", *schema.MaxLength)
+	}
+	if schema.Pattern != "" {
+		re, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			verr.add(path, "invalid schema pattern %s: %s", schema.Pattern, err)
+		} else if !re.MatchString(s) {
+			verr.add(path, "must match the pattern %s, found %q", schema.Pattern, s)
+		}
+	}
+}
+
+func validateNumber(schema *jsonschema.Schema, n float64, path string, verr *ValidationError) {
+	bound := func(limit json.Number, failed func(l float64) bool, format string) {
+		if limit == "" {
+			return
+		}
+		if l, err := limit.Float64(); err == nil && failed(l) {
+			verr.add(path, format, limit, n)
+		}
+	}
+	bound(schema.Minimum, func(l float64) bool { return n < l }, "must be >= %s, found %v")
+	bound(schema.Maximum, func(l float64) bool { return n > l }, "must be <= %s, found %v")
+	bound(schema.ExclusiveMinimum, func(l float64) bool { return n <= l }, "must be > %s, found %v")
+	bound(schema.ExclusiveMaximum, func(l float64) bool { return n >= l }, "must be < %s, found %v")
+	if schema.MultipleOf != "" {
+		if m, err := schema.MultipleOf.Float64(); err == nil && m > 0 {
+			if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+				verr.add(path, "must be a multiple of %s, found %v", schema.MultipleOf, n)
+			}
+		}
+	}
+}
+
+func countMatches(schemas []*jsonschema.Schema, value any) int {
+	matches := 0
+	for _, s := range schemas {
+		verr := ValidationError{}
+		validateSchema(s, value, "", &verr)
+		if len(verr.Violations) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+func matchesSchemaType(schemaType string, value any) bool {
+	switch schemaType {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonTypeName(value) == schemaType
+	}
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return reflect.TypeOf(value).String()
+	}
+}
+
+func jsonEqual(a any, b any) bool {
+	na, err1 := normalizeJSON(a)
+	nb, err2 := normalizeJSON(b)
+	return err1 == nil && err2 == nil && reflect.DeepEqual(na, nb)
+}
@@ -0,0 +1,131 @@
+package cc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type gridAttributes struct {
+	CellSize float64 `json:"cell_size" jsonschema:"minimum=1"`
+	Units    string  `json:"units,omitempty" jsonschema:"enum=ft,enum=m"`
+	Spacing  int     `json:"spacing,omitempty" jsonschema:"multipleOf=5"`
+}
+
+type modelAttributes struct {
+	Model   string         `json:"model"`
+	Years   []int          `json:"years,omitempty" jsonschema:"uniqueItems=true"`
+	Grid    gridAttributes `json:"grid"`
+	Output  string         `json:"output,omitempty" jsonschema:"pattern=^s3://"`
+	Restart bool           `json:"restart,omitempty"`
+}
+
+type schemaTestAction struct {
+	ActionRunnerBase
+}
+
+func (a *schemaTestAction) AttributeSchema() any {
+	return modelAttributes{}
+}
+
+func (a *schemaTestAction) Run() error {
+	attrs := modelAttributes{}
+	return a.DecodeAttributes(&attrs)
+}
+
+func TestValidateAttributeSchema(t *testing.T) {
+	RegisterActionType[schemaTestAction]("schema-action")
+	pm := newTestPluginManager(
+		Action{Name: "schema-action", IOManager: IOManager{Attributes: PayloadAttributes{
+			"model": "ras",
+			"years": []any{2020, 2021},
+			"grid":  map[string]any{"cell_size": 10, "units": "m"},
+		}}},
+		Action{Name: "schema-action", IOManager: IOManager{Attributes: PayloadAttributes{
+			"years":      []any{2020, "twenty", 2020},
+			"grid":       map[string]any{"cell_size": 0.5, "units": "km", "spacing": 12},
+			"output":     "/local/out",
+			"restart":    "maybe",
+			"cc_timeout": "10m",
+		}}},
+	)
+	err := pm.RunActions()
+	verr := &ValidationError{}
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, found %v", err)
+	}
+	paths := []string{}
+	for _, v := range verr.Violations {
+		paths = append(paths, v.Path)
+	}
+	expected := []string{
+		"$.actions[1].attributes.model",
+		"$.actions[1].attributes.grid.cell_size",
+		"$.actions[1].attributes.grid.spacing",
+		"$.actions[1].attributes.grid.units",
+		"$.actions[1].attributes.output",
+		"$.actions[1].attributes.restart",
+		"$.actions[1].attributes.years",
+		"$.actions[1].attributes.years[1]",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected violations at %v, found %s", expected, verr)
+	}
+}
+
+type coercionTestAction struct {
+	schemaTestAction
+}
+
+var coercedAttributes modelAttributes
+
+func (a *coercionTestAction) Run() error {
+	return a.DecodeAttributes(&coercedAttributes)
+}
+
+func TestAttributeSchemaCoercion(t *testing.T) {
+	RegisterActionType[coercionTestAction]("coercion-action")
+	//substituted values are strings
+	attributes := PayloadAttributes{
+		"model":   "ras",
+		"years":   []any{"2020", "2021"},
+		"grid":    map[string]any{"cell_size": "10.5", "spacing": "15"},
+		"restart": "true",
+	}
+	original := PayloadAttributes{
+		"model":   "ras",
+		"years":   []any{"2020", "2021"},
+		"grid":    map[string]any{"cell_size": "10.5", "spacing": "15"},
+		"restart": "true",
+	}
+	pm := newTestPluginManager(Action{Name: "coercion-action", IOManager: IOManager{Attributes: attributes}})
+	if _, err := pm.Plan(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	expected := modelAttributes{
+		Model:   "ras",
+		Years:   []int{2020, 2021},
+		Grid:    gridAttributes{CellSize: 10.5, Spacing: 15},
+		Restart: true,
+	}
+	if !reflect.DeepEqual(coercedAttributes, expected) {
+		t.Fatalf("expected the attributes to be decoded as the schema types, found %+v", coercedAttributes)
+	}
+	if !reflect.DeepEqual(attributes, original) {
+		t.Fatalf("expected the payload attributes to be unchanged, found %v", attributes)
+	}
+}
+
+type referenceAttributes struct {
+	Source any `json:"source" jsonschema:"anyof_ref=#/$defs/a"`
+}
+
+func TestUnsupportedSchemaKeywords(t *testing.T) {
+	err := ValidateAttributes(ReflectAttributeSchema(referenceAttributes{}), PayloadAttributes{"source": "a"}, "$.attributes")
+	if err == nil || err.Error() != "unsupported attribute schema keywords: $ref" {
+		t.Fatalf("expected the $ref keyword to be rejected, found %v", err)
+	}
+}
@@ -66,13 +66,18 @@ type PullObjectInput struct {
 }
 
 func NewCcStore(manifestArgs ...string) (CcStore, error) {
-	storeType := os.Getenv(CcStoreType)
+	return newCcStore(os.LookupEnv, manifestArgs...)
+}
+
+// newCcStore creates the CcStore selected by CC_STORE_TYPE, reading the store configuration with lookup
+func newCcStore(lookup EnvLookup, manifestArgs ...string) (CcStore, error) {
+	storeType := lookup.getenv(CcStoreType)
 
 	switch StoreType(storeType) {
 	case FSB:
-		return NewFSBCcStore(manifestArgs...)
+		return newFSBCcStore(lookup, manifestArgs...)
 	case FSS3, "": // Default to S3 if no store type specified
-		return NewS3CcStore(manifestArgs...)
+		return newS3CcStore(lookup, manifestArgs...)
 	default:
 		return nil, fmt.Errorf("unsupported store type: %s", storeType)
 	}
@@ -16,21 +16,26 @@ type FSBCcStore struct {
 	manifestId     string
 	payloadId      string
 	storeType      StoreType
+	lookup         EnvLookup //reads the store environment variables. nil reads the process environment
 }
 
 // NewFSBCcStore creates a new FSB CcStore instance
 func NewFSBCcStore(manifestArgs ...string) (CcStore, error) {
+	return newFSBCcStore(os.LookupEnv, manifestArgs...)
+}
+
+func newFSBCcStore(lookup EnvLookup, manifestArgs ...string) (CcStore, error) {
 	var manifestId string
 	var payloadId string
 	if len(manifestArgs) > 1 {
 		manifestId = manifestArgs[0]
 		payloadId = manifestArgs[1]
 	} else {
-		manifestId = os.Getenv(CcManifestId)
-		payloadId = os.Getenv(CcPayloadId)
+		manifestId = lookup.getenv(CcManifestId)
+		payloadId = lookup.getenv(CcPayloadId)
 	}
 
-	rootPath := os.Getenv(FsbRootPath)
+	rootPath := lookup.getenv(FsbRootPath)
 	if rootPath == "" {
 		rootPath = "/data" // default local root path
 	}
@@ -40,7 +45,7 @@ func NewFSBCcStore(manifestArgs ...string) (CcStore, error) {
 		return nil, fmt.Errorf("failed to create root directory: %w", err)
 	}
 
-	return &FSBCcStore{localRootPath, rootPath, manifestId, payloadId, FSB}, nil
+	return &FSBCcStore{localRootPath, rootPath, manifestId, payloadId, FSB, lookup}, nil
 }
 
 // HandlesDataStoreType determines if a datasource is handled by this store
@@ -53,6 +58,11 @@ func (fs *FSBCcStore) RootPath() string {
 	return fs.localRootPath
 }
 
+// remoteRoot is the root path PutObject writes under
+func (fs *FSBCcStore) remoteRoot() string {
+	return fs.remoteRootPath
+}
+
 // PutObject stores a file in the local file system
 func (fs *FSBCcStore) PutObject(poi PutObjectInput) error {
 	destPath := filepath.Join(fs.remoteRootPath, fs.manifestId, fmt.Sprintf("%s.%s", poi.FileName, poi.FileExtension))
@@ -121,7 +131,7 @@ func (fs *FSBCcStore) SetPayload(p Payload) error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	_, shouldFormat := os.LookupEnv(CcPayloadFormatted)
+	_, shouldFormat := fs.lookup.lookupKey(CcPayloadFormatted)
 	var data []byte
 	var err error
 
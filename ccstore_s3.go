@@ -25,23 +25,28 @@ type S3CcStore struct {
 	manifestId     string
 	payloadId      string
 	storeType      StoreType
+	lookup         EnvLookup //reads the store environment variables. nil reads the process environment
 }
 
 // NewCcStore produces a CcStore backed by an S3 bucket
 // if no arguments are supplied, the manifestid will get loaded from the environment
 // @TODO: make sure file operations use io and readers and stream chunks.  avoid large files in memory.
 func NewS3CcStore(manifestArgs ...string) (CcStore, error) {
+	return newS3CcStore(os.LookupEnv, manifestArgs...)
+}
+
+func newS3CcStore(lookup EnvLookup, manifestArgs ...string) (CcStore, error) {
 	var manifestId string
 	var payloadId string
 	if len(manifestArgs) > 1 {
 		manifestId = manifestArgs[0]
 		payloadId = manifestArgs[1]
 	} else {
-		manifestId = os.Getenv(CcManifestId)
-		payloadId = os.Getenv(CcPayloadId)
+		manifestId = lookup.getenv(CcManifestId)
+		payloadId = lookup.getenv(CcPayloadId)
 	}
-	awsconfig := BuildS3Config(CcProfile)
-	rootPath := os.Getenv(CcRootPath)
+	awsconfig := buildS3Config(lookup, CcProfile)
+	rootPath := lookup.getenv(CcRootPath)
 	if rootPath == "" {
 		rootPath = RemoteRootPath //set to default
 	}
@@ -50,7 +55,7 @@ func NewS3CcStore(manifestArgs ...string) (CcStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &S3CcStore{fs, localRootPath, rootPath, manifestId, payloadId, FSS3}, nil
+	return &S3CcStore{fs, localRootPath, rootPath, manifestId, payloadId, FSS3, lookup}, nil
 }
 
 // HandlesDataSource determines if a datasource is handled by this store
@@ -63,6 +68,11 @@ func (ws *S3CcStore) RootPath() string {
 	return ws.localRootPath
 }
 
+// remoteRoot is the root path PutObject writes under
+func (ws *S3CcStore) remoteRoot() string {
+	return ws.remoteRootPath
+}
+
 // PutObject takes a file by name from the localRootPath (see RootPath) and pushes it into S3 to the remoteRootPath concatenated with the manifestId
 func (ws *S3CcStore) PutObject(poi PutObjectInput) error {
 	s3path := filestore.PathConfig{Path: fmt.Sprintf("%s/%s/%s.%s", ws.remoteRootPath, ws.manifestId, poi.FileName, poi.FileExtension)}
@@ -130,7 +140,7 @@ func (ws *S3CcStore) GetPayload() (Payload, error) {
 // SetPayload sets a payload. This is designed for cloud compute to use, please do not use this method in a plugin.
 func (ws *S3CcStore) SetPayload(p Payload) error {
 	s3path := filestore.PathConfig{Path: fmt.Sprintf("%s/%s/%s", ws.remoteRootPath, ws.payloadId, "payload")}
-	_, shouldFormat := os.LookupEnv(CcPayloadFormatted)
+	_, shouldFormat := ws.lookup.lookupKey(CcPayloadFormatted)
 	var data []byte
 	var err error
 	if shouldFormat {
@@ -178,18 +188,23 @@ func (ws *S3CcStore) PullObject(input PullObjectInput) error {
 }
 
 func BuildS3Config(profile string) filestore.S3FSConfig {
+	return buildS3Config(os.LookupEnv, profile)
+}
+
+// buildS3Config builds the S3 configuration of a profile, reading the credentials with lookup
+func buildS3Config(lookup EnvLookup, profile string) filestore.S3FSConfig {
 	template := "%s_%s"
 	if profile == "" {
 		template = "%s%s"
 	}
 	awsconfig := filestore.S3FSConfig{
 		Credentials: filestore.S3FS_Static{
-			S3Id:  os.Getenv(fmt.Sprintf(template, profile, AwsAccessKeyId)),
-			S3Key: os.Getenv(fmt.Sprintf(template, profile, AwsSecretAccessKey)),
+			S3Id:  lookup.getenv(fmt.Sprintf(template, profile, AwsAccessKeyId)),
+			S3Key: lookup.getenv(fmt.Sprintf(template, profile, AwsSecretAccessKey)),
 		},
-		S3Region:    os.Getenv(fmt.Sprintf(template, profile, AwsDefaultRegion)),
-		S3Bucket:    os.Getenv(fmt.Sprintf(template, profile, AwsS3Bucket)),
-		AltEndpoint: os.Getenv(fmt.Sprintf(template, profile, AwsS3Endpoint)),
+		S3Region:    lookup.getenv(fmt.Sprintf(template, profile, AwsDefaultRegion)),
+		S3Bucket:    lookup.getenv(fmt.Sprintf(template, profile, AwsS3Bucket)),
+		AltEndpoint: lookup.getenv(fmt.Sprintf(template, profile, AwsS3Endpoint)),
 		AwsOptions: []func(*config.LoadOptions) error{
 			config.WithRetryer(func() aws.Retryer {
 				return retry.AddWithMaxAttempts(retry.NewStandard(), 5)
@@ -0,0 +1,223 @@
+package cc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CcEnableCheckpoints is the environment variable that enables checkpoints
+const CcEnableCheckpoints = "CC_ENABLE_CHECKPOINTS"
+
+// CcForceRerun is the environment variable that forces every action to run
+// even when a checkpoint records it as completed
+const CcForceRerun = "CC_FORCE_RERUN"
+
+const checkpointFileName = "checkpoint"
+
+// ActionCheckpoint records a completed action and the fingerprint of its resolved inputs
+type ActionCheckpoint struct {
+	Action      string    `json:"action"`
+	Index       int       `json:"index"`
+	Fingerprint string    `json:"fingerprint"`
+	Completed   time.Time `json:"completed"`
+}
+
+// Checkpoint is the set of completed actions for a manifest, payload and event.
+// It is stored in the CcStore under the manifest as checkpoint-<payload>-<event identifier>.json.
+type Checkpoint struct {
+	Manifest        string             `json:"manifest"`
+	Payload         string             `json:"payload"`
+	EventIdentifier string             `json:"event_identifier"`
+	Actions         []ActionCheckpoint `json:"actions"`
+}
+
+// remoteRootStore is implemented by the CcStores that checkpoints can be read back from
+type remoteRootStore interface {
+	remoteRoot() string
+}
+
+type checkpointer struct {
+	mu         sync.Mutex
+	store      CcStore
+	root       string
+	fileName   string
+	checkpoint Checkpoint
+}
+
+// newCheckpointer loads the checkpoint of the current event from the CcStore when checkpoints are enabled.
+// A missing or unreadable checkpoint starts a new one.
+func (pm *PluginManager) newCheckpointer() *checkpointer {
+	if pm.ccStore == nil || !pm.checkpointsEnabled() {
+		return nil
+	}
+	rrs, ok := pm.ccStore.(remoteRootStore)
+	if !ok {
+		pm.Logger.Warn("checkpoints are not supported by the cc store")
+		return nil
+	}
+	cp := checkpointer{
+		store:    pm.ccStore,
+		root:     rrs.remoteRoot(),
+		fileName: fmt.Sprintf("%s-%s-%s", checkpointFileName, pm.payloadId, pm.EventIdentifier),
+		checkpoint: Checkpoint{
+			Manifest:        pm.manifestId,
+			Payload:         pm.payloadId,
+			EventIdentifier: pm.EventIdentifier,
+		},
+	}
+	data, err := pm.ccStore.GetObject(GetObjectInput{
+		SourceRootPath: cp.root,
+		FileName:       cp.fileName,
+		FileExtension:  "json",
+	})
+	if err == nil {
+		err = json.Unmarshal(data, &cp.checkpoint)
+		if err != nil {
+			pm.Logger.Warn("ignoring invalid checkpoint", "checkpoint", cp.fileName, "error", err.Error())
+			cp.checkpoint.Actions = nil
+		}
+	}
+	return &cp
+}
+
+// completed returns true if the action was recorded with the same fingerprint.
+// An empty fingerprint never matches.
+func (cp *checkpointer) completed(index int, action Action, fingerprint string) bool {
+	if cp == nil || fingerprint == "" {
+		return false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for _, ac := range cp.checkpoint.Actions {
+		if ac.Index == index && ac.Action == action.Name && ac.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// record adds a completed action to the checkpoint and writes the checkpoint to the CcStore.
+// Actions with an empty fingerprint are not recorded.
+func (cp *checkpointer) record(index int, action Action, fingerprint string) error {
+	if cp == nil || fingerprint == "" {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	actions := []ActionCheckpoint{}
+	for _, ac := range cp.checkpoint.Actions {
+		if ac.Index != index {
+			actions = append(actions, ac)
+		}
+	}
+	cp.checkpoint.Actions = append(actions, ActionCheckpoint{
+		Action:      action.Name,
+		Index:       index,
+		Fingerprint: fingerprint,
+		Completed:   time.Now(),
+	})
+	data, err := json.Marshal(cp.checkpoint)
+	if err != nil {
+		return err
+	}
+	return cp.store.PutObject(PutObjectInput{
+		FileName:      cp.fileName,
+		FileExtension: "json",
+		ObjectState:   Memory,
+		Data:          data,
+	})
+}
+
+// inputObjectInfo identifies the contents of an action input path by its size and modification time
+type inputObjectInfo struct {
+	DataSource string    `json:"data_source"`
+	PathKey    string    `json:"path_key"`
+	Size       int64     `json:"size"`
+	Modified   time.Time `json:"modified"`
+}
+
+// actionFingerprint hashes the resolved action along with the payload level
+// attributes, stores and data sources the action can reach through its parent
+// and the size and modification time of each action input path.
+//
+// The input info is read for local file system (FSB) inputs and inputs in stores whose session
+// implements StoreObjectInfo.  When the info of an input can not be read the action is treated as
+// stale: the fingerprint is empty and the action always runs.
+func (pm *PluginManager) actionFingerprint(action Action) (string, error) {
+	inputs, err := inputObjectInfos(action)
+	if err != nil {
+		pm.Logger.Info("action inputs can not be checkpointed", "action", action.Name, "error", err.Error())
+		return "", nil
+	}
+	data, err := json.Marshal(struct {
+		Payload IOManager         `json:"payload"`
+		Action  Action            `json:"action"`
+		Inputs  []inputObjectInfo `json:"inputs,omitempty"`
+	}{pm.IOManager, action, inputs})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// inputObjectInfos reads the object info of every action input path.
+// It returns an error for the first input whose info can not be read.
+func inputObjectInfos(action Action) ([]inputObjectInfo, error) {
+	var infos []inputObjectInfo
+	for _, ds := range action.Inputs {
+		store, err := action.GetStore(ds.StoreName)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range sortedKeys(ds.Paths) {
+			var info fs.FileInfo
+			switch session := store.session().(type) {
+			case StoreObjectInfo:
+				info, err = session.GetObjectInfo(ds.Paths[key])
+			default:
+				if store.StoreType != FSB {
+					return nil, fmt.Errorf("store %s does not report object info", ds.StoreName)
+				}
+				var path string
+				path, err = action.GetAbsolutePath(ds.StoreName, ds.Name, key)
+				if err == nil {
+					info, err = os.Stat(path)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("input %s path %s: %w", ds.Name, key, err)
+			}
+			infos = append(infos, inputObjectInfo{
+				DataSource: ds.Name,
+				PathKey:    key,
+				Size:       info.Size(),
+				Modified:   info.ModTime().UTC(),
+			})
+		}
+	}
+	return infos, nil
+}
+
+func (pm *PluginManager) checkpointsEnabled() bool {
+	if pm.config.EnableCheckpoints {
+		return true
+	}
+	enabled, _ := strconv.ParseBool(pm.getenv(CcEnableCheckpoints))
+	return enabled
+}
+
+func (pm *PluginManager) forceRerun() bool {
+	if pm.config.ForceRerun {
+		return true
+	}
+	force, _ := strconv.ParseBool(pm.getenv(CcForceRerun))
+	return force
+}
@@ -0,0 +1,111 @@
+package cc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunActionsCheckpoint(t *testing.T) {
+	t.Setenv(FsbRootPath, t.TempDir())
+	store, err := NewFSBCcStore("checkpoint-manifest", "checkpoint-payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	registerFlakyAction("checkpoint-action", 0, "", &attempts)
+	dataRoot := t.TempDir()
+	terrain := filepath.Join(dataRoot, "terrain.tif")
+	newManager := func(plan string) *PluginManager {
+		pm := newTestPluginManager(Action{
+			Name: "checkpoint-action",
+			IOManager: IOManager{
+				Attributes: PayloadAttributes{"plan": plan},
+				Stores:     []DataStore{{Name: "local", StoreType: FSB, Parameters: PayloadAttributes{"root": dataRoot}}},
+				Inputs:     []DataSource{{Name: "terrain", StoreName: "local", Paths: map[string]string{"default": "terrain.tif"}}},
+			},
+		})
+		pm.ccStore = store
+		pm.payloadId = "checkpoint-payload"
+		pm.EventIdentifier = "12"
+		pm.config.EnableCheckpoints = true
+		return pm
+	}
+
+	runs := []struct {
+		name     string
+		plan     string
+		terrain  string
+		force    bool
+		expected int
+	}{
+		{"first run", "04", "v1", false, 1},
+		{"rerun is skipped", "04", "v1", false, 1},
+		{"changed inputs rerun", "05", "v1", false, 2},
+		{"forced rerun", "05", "v1", true, 3},
+		{"changed input data reruns", "05", "v2 terrain", false, 4},
+	}
+	for i, run := range runs {
+		if i == 0 || run.terrain != runs[i-1].terrain {
+			if err := os.WriteFile(terrain, []byte(run.terrain), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		pm := newManager(run.plan)
+		pm.config.ForceRerun = run.force
+		if err := pm.RunActions(); err != nil {
+			t.Fatal(err)
+		}
+		if attempts != run.expected {
+			t.Fatalf("%s: expected %d runs, found %d", run.name, run.expected, attempts)
+		}
+	}
+
+	pm := newManager("05")
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	if result := pm.RunSummary().Actions[0]; result.Status != ActionSkipped || !result.Checkpointed {
+		t.Fatalf("expected a checkpointed result: %+v", result)
+	}
+}
+
+func TestRunActionsCheckpointStale(t *testing.T) {
+	t.Setenv(FsbRootPath, t.TempDir())
+	store, err := NewFSBCcStore("checkpoint-manifest", "checkpoint-payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	registerFlakyAction("stale-action", 0, "", &attempts)
+	runs := []struct {
+		name    string
+		enabled bool
+		inputs  []DataSource
+	}{
+		{"checkpoints disabled", false, nil},
+		{"input without object info", true, []DataSource{{Name: "grid", StoreName: "remote", Paths: map[string]string{"default": "grid.tif"}}}},
+	}
+	for _, run := range runs {
+		attempts = 0
+		for range 2 {
+			pm := newTestPluginManager(Action{
+				Name: "stale-action",
+				IOManager: IOManager{
+					Stores: []DataStore{{Name: "remote", StoreType: FSS3, Session: &slowWriter{}}},
+					Inputs: run.inputs,
+				},
+			})
+			pm.ccStore = store
+			pm.payloadId = "checkpoint-payload"
+			pm.EventIdentifier = "13"
+			pm.config.EnableCheckpoints = run.enabled
+			if err := pm.RunActions(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if attempts != 2 {
+			t.Fatalf("%s: expected the action to run twice, found %d runs", run.name, attempts)
+		}
+	}
+}
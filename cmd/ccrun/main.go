@@ -0,0 +1,36 @@
+// ccrun reports the execution plan of a cloud compute payload on the local file system.
+//
+// The standalone command has no actions registered, so it always runs in dry run mode
+// and can not run a payload.  To run a plugin locally, register the plugin actions and
+// call cc.RunLocalMain from the plugin main function.
+//
+//	ccrun -payload ./payload.json -event 1 -root ./cc_store
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	cc "github.com/usace-cloud-compute/cc-go-sdk"
+)
+
+func main() {
+	input, err := cc.ParseLocalRunFlags("ccrun", os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	//without registered actions only the execution plan can be reported
+	input.Config.DryRun = true
+	input.Config.DryRunOutput = os.Stdout
+	if err := cc.RunLocal(input); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
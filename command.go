@@ -0,0 +1,234 @@
+package cc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+const (
+	commandStdout = "stdout"
+	commandStderr = "stderr"
+
+	//number of trailing stderr lines kept in a CommandError
+	commandErrorLines = 20
+
+	//longest output line logged as a single record.  longer lines are split
+	commandMaxLine = 1024 * 1024
+)
+
+// commandWaitDelay is the time allowed for the output pipes to close after the command exits
+// or is killed.  A background process started by the command can hold the pipes open.
+// It is replaced in tests.
+var commandWaitDelay = 10 * time.Second
+
+// CommandInput describes an external executable run by RunCommand
+type CommandInput struct {
+	Name         string            //executable name or path
+	Args         []string          //optional arguments
+	Dir          string            //optional - defaults to the action workspace
+	Env          map[string]string //optional - added to the process and action attribute environment
+	Timeout      time.Duration     //optional - limits the command in addition to the action timeout
+	SuccessCodes []int             //optional - non zero exit codes that are treated as success
+	Stdin        io.Reader         //optional
+}
+
+// CommandError is returned when a command exits with a code that is not a success code
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   []string //the last lines written to stderr
+}
+
+func (ce *CommandError) Error() string {
+	msg := fmt.Sprintf("command %s exited with code %d", ce.Command, ce.ExitCode)
+	if len(ce.Stderr) > 0 {
+		msg += ": " + strings.Join(ce.Stderr, "\n")
+	}
+	return msg
+}
+
+// RunCommand runs an external executable for the action and returns its exit code.
+//
+// The command runs in the action workspace unless input.Dir is set and is killed when the
+// action context is cancelled.  The environment is the process environment, the current event of a
+// multi-event run, the "cc_env" action attribute and input.Env, in increasing precedence.  Each stdout and stderr line is logged
+// to the CcLogger with action and stream fields.  A non zero exit code that is not listed in
+// input.SuccessCodes returns a CommandError.
+func (arb *ActionRunnerBase) RunCommand(input CommandInput) (int, error) {
+	if input.Dir == "" {
+		dir, err := arb.Workspace()
+		if err != nil {
+			return -1, err
+		}
+		input.Dir = dir
+	}
+	env, err := commandAttributeEnv(arb.Action.Attributes)
+	if err != nil {
+		return -1, fmt.Errorf("action %s: %w", arb.ActionName, err)
+	}
+	for k, v := range input.Env {
+		env[k] = v
+	}
+	input.Env = withEnv(arb.PluginManager.eventEnv(), env)
+	return runCommand(arb.Context(), arb.PluginManager.Logger, arb.ActionName, input)
+}
+
+// RunCommand runs an external executable outside of an action.
+// It behaves like ActionRunnerBase.RunCommand without the action workspace and attribute environment.
+func (pm *PluginManager) RunCommand(ctx context.Context, input CommandInput) (int, error) {
+	input.Env = withEnv(pm.eventEnv(), input.Env)
+	return runCommand(ctx, pm.Logger, "", input)
+}
+
+// withEnv returns a copy of base with the variables of overrides replacing those of base
+func withEnv(base map[string]string, overrides map[string]string) map[string]string {
+	env := make(map[string]string, len(base)+len(overrides))
+	maps.Copy(env, base)
+	maps.Copy(env, overrides)
+	return env
+}
+
+func commandAttributeEnv(attrs PayloadAttributes) (map[string]string, error) {
+	env := map[string]string{}
+	val, ok := attrs[ActionEnvAttr]
+	if !ok {
+		return env, nil
+	}
+	vars, err := cast.ToStringMapE(val)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s attribute: %w", ActionEnvAttr, err)
+	}
+	for k, v := range vars {
+		s, err := cast.ToStringE(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s attribute %s: %w", ActionEnvAttr, k, err)
+		}
+		env[k] = s
+	}
+	return env, nil
+}
+
+func runCommand(ctx context.Context, logger *CcLogger, action string, input CommandInput) (int, error) {
+	if input.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, input.Timeout, fmt.Errorf("command %s exceeded its timeout of %s: %w", input.Name, input.Timeout, context.DeadlineExceeded))
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, input.Name, input.Args...)
+	cmd.Dir = input.Dir
+	cmd.Stdin = input.Stdin
+	cmd.WaitDelay = commandWaitDelay
+	cmd.Env = os.Environ()
+	for _, k := range sortedKeys(input.Env) {
+		cmd.Env = append(cmd.Env, k+"="+input.Env[k])
+	}
+
+	logArgs := []any{"command", input.Name}
+	if action != "" {
+		logArgs = append(logArgs, "action", action)
+	}
+	stderrTail := &lineTail{max: commandErrorLines}
+	stdout := newLineLogger(logger, logArgs, commandStdout, nil)
+	stderr := newLineLogger(logger, logArgs, commandStderr, stderrTail)
+	//exec.Cmd copies the output to the writers so Wait and WaitDelay bound the copying
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	logger.Action("starting command", append(logArgs, "args", input.Args, "dir", input.Dir)...)
+	if err := cmd.Start(); err != nil {
+		return -1, fmt.Errorf("failed to start command %s: %w", input.Name, err)
+	}
+	err := cmd.Wait()
+	stdout.flush()
+	stderr.flush()
+
+	exitCode := cmd.ProcessState.ExitCode()
+	if ctx.Err() != nil {
+		return exitCode, fmt.Errorf("command %s was cancelled: %w", input.Name, context.Cause(ctx))
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return exitCode, fmt.Errorf("command %s failed: %w", input.Name, err)
+	}
+	logger.Action("command completed", append(logArgs, "exit_code", exitCode)...)
+	if exitCode == 0 || slices.Contains(input.SuccessCodes, exitCode) {
+		return exitCode, nil
+	}
+	return exitCode, &CommandError{input.Name, exitCode, stderrTail.lines}
+}
+
+// lineLogger is an io.Writer that logs each line written to it
+type lineLogger struct {
+	mu     sync.Mutex
+	logger *CcLogger
+	args   []any
+	tail   *lineTail
+	buf    []byte
+}
+
+func newLineLogger(logger *CcLogger, logArgs []any, stream string, tail *lineTail) *lineLogger {
+	return &lineLogger{logger: logger, args: append(slices.Clone(logArgs), "stream", stream), tail: tail}
+}
+
+func (ll *lineLogger) Write(p []byte) (int, error) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+	ll.buf = append(ll.buf, p...)
+	for {
+		i := bytes.IndexByte(ll.buf, '\n')
+		if i < 0 {
+			break
+		}
+		ll.log(ll.buf[:i])
+		ll.buf = ll.buf[i+1:]
+	}
+	for len(ll.buf) >= commandMaxLine {
+		ll.log(ll.buf[:commandMaxLine])
+		ll.buf = ll.buf[commandMaxLine:]
+	}
+	return len(p), nil
+}
+
+// flush logs a trailing line without a newline
+func (ll *lineLogger) flush() {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+	if len(ll.buf) > 0 {
+		ll.log(ll.buf)
+		ll.buf = nil
+	}
+}
+
+func (ll *lineLogger) log(line []byte) {
+	text := strings.TrimSuffix(string(line), "\r")
+	ll.logger.Action(text, ll.args...)
+	if ll.tail != nil {
+		ll.tail.add(text)
+	}
+}
+
+// lineTail keeps the last max lines
+type lineTail struct {
+	max   int
+	lines []string
+}
+
+func (lt *lineTail) add(line string) {
+	lt.lines = append(lt.lines, line)
+	if len(lt.lines) > lt.max {
+		lt.lines = lt.lines[1:]
+	}
+}
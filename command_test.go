@@ -0,0 +1,111 @@
+package cc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+type commandTestAction struct {
+	ActionRunnerBase
+	script       string
+	successCodes []int
+	exitCode     *int
+	dir          *string
+}
+
+func (a *commandTestAction) Run() error {
+	*a.dir, _ = a.Workspace()
+	code, err := a.RunCommand(CommandInput{
+		Name:         "sh",
+		Args:         []string{"-c", a.script},
+		Env:          map[string]string{"RUN": "04"},
+		SuccessCodes: a.successCodes,
+	})
+	*a.exitCode = code
+	return err
+}
+
+func TestRunCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	var exitCode int
+	var dir string
+	register := func(name string, script string, successCodes ...int) {
+		RegisterActionFactory(name, func(pm *PluginManager, action Action) (ActionRunner, error) {
+			return &commandTestAction{ActionRunnerBase{ActionName: action.Name, PluginManager: pm, Action: action}, script, successCodes, &exitCode, &dir}, nil
+		})
+	}
+	register("command-success", `echo "model $MODEL run $RUN in $(pwd)"; echo warning >&2; exit 3`, 3)
+	register("command-failure", `echo "bad input" >&2; exit 2`)
+
+	buf := &bytes.Buffer{}
+	newManager := func(action string) *PluginManager {
+		pm := newTestPluginManager(Action{
+			Name:      action,
+			IOManager: IOManager{Attributes: PayloadAttributes{ActionEnvAttr: map[string]any{"MODEL": "ras", "RUN": "01"}}},
+		})
+		pm.Logger = &CcLogger{Logger: slog.New(slog.NewJSONHandler(buf, nil))}
+		pm.ccStore = &FSBCcStore{localRootPath: t.TempDir(), remoteRootPath: t.TempDir()}
+		return pm
+	}
+
+	if err := newManager("command-success").RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	logs := buf.String()
+	expected := []string{
+		`"msg":"model ras run 04 in ` + dir + `","command":"sh","action":"command-success","stream":"stdout"`,
+		`"msg":"warning","command":"sh","action":"command-success","stream":"stderr"`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(logs, e) {
+			t.Fatalf("expected the log to contain %s:\n%s", e, logs)
+		}
+	}
+	if exitCode != 3 {
+		t.Fatalf("expected exit code 3, found %d", exitCode)
+	}
+
+	err := newManager("command-failure").RunActions()
+	cmdErr := &CommandError{}
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != 2 || !strings.Contains(cmdErr.Error(), "bad input") {
+		t.Fatalf("expected a command error with exit code 2, found %v", err)
+	}
+}
+
+func TestRunCommandBackgroundProcess(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	commandWaitDelay = 100 * time.Millisecond
+	defer func() { commandWaitDelay = 10 * time.Second }()
+
+	buf := &bytes.Buffer{}
+	pm := newTestPluginManager()
+	pm.Logger = &CcLogger{Logger: slog.New(slog.NewJSONHandler(buf, nil))}
+	done := make(chan error, 1)
+	go func() {
+		//the background sleep inherits the output pipes and keeps them open after sh exits
+		_, err := pm.RunCommand(context.Background(), CommandInput{
+			Name:    "sh",
+			Args:    []string{"-c", "sleep 100 & echo x"},
+			Timeout: 200 * time.Millisecond,
+		})
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected RunCommand to return when the output pipes are held open by a background process")
+	}
+	if !strings.Contains(buf.String(), `"msg":"x","command":"sh","stream":"stdout"`) {
+		t.Fatalf("expected the command output to be logged:\n%s", buf)
+	}
+}
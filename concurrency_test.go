@@ -0,0 +1,149 @@
+package cc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memStore is a thread safe in memory store used to exercise the manager from many goroutines
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (ms *memStore) Connect(ds DataStore) (any, error) {
+	return &memStore{objects: map[string][]byte{}}, nil
+}
+
+func (ms *memStore) GetSession() any {
+	return ms
+}
+
+func (ms *memStore) Get(path string, datapath string) (io.ReadCloser, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	data, ok := ms.objects[path]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", path)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (ms *memStore) Put(reader io.Reader, path string, datapath string) (int, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.objects[path] = data
+	return len(data), nil
+}
+
+type concurrentTestAction struct {
+	ActionRunnerBase
+	started  *atomic.Int32 //nil for actions that do not wait for the others
+	parallel int32
+}
+
+func (a *concurrentTestAction) Run() error {
+	if a.started != nil {
+		//wait for every parallel action to start so the test fails if they are run one at a time
+		a.started.Add(1)
+		deadline := time.Now().Add(5 * time.Second)
+		for a.started.Load() < a.parallel {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%d of %d actions started in parallel", a.started.Load(), a.parallel)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vars := map[string]string{"part": a.ActionName + "-" + strconv.Itoa(i)}
+			input := DataSourceOpInput{DataSourceName: "results", PathKey: "default", TemplateVars: vars}
+			if _, err := a.Action.Put(PutOpInput{SrcReader: bytes.NewReader([]byte(vars["part"])), DataSourceOpInput: input}); err != nil {
+				errs <- err
+				return
+			}
+			data, err := a.PluginManager.Get(DataSourceOpInput{DataSourceName: "parts", PathKey: "default", TemplateVars: vars})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(data) != vars["part"] {
+				errs <- fmt.Errorf("expected %s, found %s", vars["part"], data)
+			}
+			a.PluginManager.RunSummary()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// TestConcurrentManagerAccess is intended to be run with the race detector (go test -race)
+func TestConcurrentManagerAccess(t *testing.T) {
+	registry := DataStoreTypeRegistryMap{}
+	registry.Register("MEM", memStore{})
+	const parallel = 4
+	started := &atomic.Int32{}
+	RegisterActionFactory("concurrent-setup", func(pm *PluginManager, action Action) (ActionRunner, error) {
+		return &concurrentTestAction{ActionRunnerBase: ActionRunnerBase{ActionName: action.Name, PluginManager: pm, Action: action}}, nil
+	})
+	RegisterActionFactory("concurrent-action", func(pm *PluginManager, action Action) (ActionRunner, error) {
+		return &concurrentTestAction{ActionRunnerBase{ActionName: action.Name, PluginManager: pm, Action: action}, started, parallel}, nil
+	})
+	payload := Payload{
+		IOManager: IOManager{
+			Stores:  []DataStore{{Name: "mem", StoreType: "MEM"}},
+			Inputs:  []DataSource{{Name: "parts", StoreName: "mem", Paths: map[string]string{"default": "results/{VAR::part}.txt"}}},
+			Outputs: []DataSource{{Name: "results", StoreName: "mem", Paths: map[string]string{"default": "results/{VAR::part}.txt"}}},
+		},
+	}
+	//the actions depend only on the setup action so they are independent of each other and run in parallel
+	payload.Actions = append(payload.Actions, Action{Name: "concurrent-setup"})
+	for range parallel {
+		payload.Actions = append(payload.Actions, Action{Name: "concurrent-action", DependsOn: []string{"concurrent-setup"}})
+	}
+	pm, err := initTestPluginManager(t, WithPayload(payload), WithStoreRegistry(registry), WithConfig(PluginManagerConfig{MaxParallelActions: parallel}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := pm.GetStore("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := pm.Actions[0].GetStore("mem"); again != store {
+		t.Fatal("expected store lookups to return the same reference")
+	}
+	if store.sessionMu != &pm.sessionMu {
+		t.Fatal("expected the store session to be guarded by the manager lock")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			//reconnecting reuses the cached session while the actions read it
+			if err := pm.connectStores(&pm.Stores); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
@@ -3,7 +3,9 @@ package cc
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"reflect"
+	"sync"
 
 	"github.com/google/uuid"
 	filestore "github.com/usace-cloud-compute/filesapi"
@@ -25,11 +27,16 @@ func (registry DataStoreTypeRegistryMap) New(storeType StoreType) (any, error) {
 
 var DataStoreTypeRegistry = make(DataStoreTypeRegistryMap)
 
-func registerStoreTypes() {
-	//DataStoreTypeRegistry.Register(S3, S3DataStore{})
-	DataStoreTypeRegistry.Register(FSS3, FileDataStore[filestore.S3FS]{})
-	DataStoreTypeRegistry.Register(FSB, FileDataStore[filestore.BlockFS]{})
+var registerStoreTypesOnce sync.Once
 
+// registerStoreTypes adds the built in store types to the DataStoreTypeRegistry.
+// The registry is only written once so that several plugin managers can be initialized in one process.
+func registerStoreTypes() {
+	registerStoreTypesOnce.Do(func() {
+		//DataStoreTypeRegistry.Register(S3, S3DataStore{})
+		DataStoreTypeRegistry.Register(FSS3, FileDataStore[filestore.S3FS]{})
+		DataStoreTypeRegistry.Register(FSB, FileDataStore[filestore.BlockFS]{})
+	})
 }
 
 type DataStore struct {
@@ -39,6 +46,38 @@ type DataStore struct {
 	DsProfile  string            `json:"profile,omitempty" yaml:"profile"`
 	Parameters PayloadAttributes `json:"params,omitempty" yaml:"params"`
 	Session    any               `json:"-" yaml:"-"` //reference to the actual connection native to the data store
+	sessionMu  *sync.RWMutex     //session lock of the PluginManager that connected the store
+}
+
+// session returns the store session.  Stores connected by a PluginManager read it under the manager session lock
+// so stores can be connected while other goroutines use them.
+func (ds *DataStore) session() any {
+	if ds.sessionMu == nil {
+		return ds.Session
+	}
+	ds.sessionMu.RLock()
+	defer ds.sessionMu.RUnlock()
+	return ds.Session
+}
+
+func (ds *DataStore) setSession(session any) {
+	if ds.sessionMu == nil {
+		ds.Session = session
+		return
+	}
+	ds.sessionMu.Lock()
+	defer ds.sessionMu.Unlock()
+	ds.Session = session
+}
+
+// config returns a copy of the store read under the session lock
+func (ds *DataStore) config() DataStore {
+	if ds.sessionMu == nil {
+		return *ds
+	}
+	ds.sessionMu.RLock()
+	defer ds.sessionMu.RUnlock()
+	return *ds
 }
 
 type ConnectionDataStore interface {
@@ -46,6 +85,12 @@ type ConnectionDataStore interface {
 	GetSession() any
 }
 
+// envConnector is implemented by connection data stores that read their connection
+// configuration (i.e. profile credentials) from the plugin manager env lookup
+type envConnector interface {
+	connectWithEnv(ds DataStore, lookup EnvLookup) (any, error)
+}
+
 type StoreReader interface {
 	Get(path string, datapath string) (io.ReadCloser, error)
 }
@@ -54,6 +99,12 @@ type StoreWriter interface {
 	Put(srcReader io.Reader, destPath string, destDataPath string) (int, error)
 }
 
+// StoreObjectInfo is implemented by store sessions that can report the size and modification
+// time of an object.  Checkpoints use it to detect changed input data.
+type StoreObjectInfo interface {
+	GetObjectInfo(path string) (fs.FileInfo, error)
+}
+
 // Reference to a specific resource in a DataStore FILE, DB, etc
 // The credential attribute is the credential prefix
 // used to identify credentials in the environment.
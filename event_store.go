@@ -480,7 +480,7 @@ func (rs *Recordset) Create() error {
 	if err != nil {
 		return err
 	}
-	if mds, ok := tdb.Session.(MultiDimensionalArrayStore); ok {
+	if mds, ok := tdb.session().(MultiDimensionalArrayStore); ok {
 		input, _ := rs.bds.BuildCreateArrayInput(rs.datapath)
 		return mds.CreateArray(input)
 	}
@@ -492,7 +492,7 @@ func (rs *Recordset) Write(buff any) error {
 	if err != nil {
 		return err
 	}
-	if mds, ok := tdb.Session.(MultiDimensionalArrayStore); ok {
+	if mds, ok := tdb.session().(MultiDimensionalArrayStore); ok {
 		input := rs.bds.BuildPutArrayInput(rs.datapath, ARRAY_DENSE) //@TODO check array type for all BuildPutArray
 		return mds.PutArray(input)
 	}
@@ -508,7 +508,7 @@ func (rs *Recordset) Read(recrange ...int64) (*ArrayResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	if mds, ok := tdb.Session.(MultiDimensionalArrayStore); ok {
+	if mds, ok := tdb.session().(MultiDimensionalArrayStore); ok {
 		input := GetArrayInput{
 			DataPath:    rs.datapath,
 			BufferRange: bufferRange,
@@ -0,0 +1,231 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventResult reports the outcome of one event in a multi-event run
+type EventResult struct {
+	EventIdentifier string    `json:"event_identifier"`
+	Status          Status    `json:"status"`
+	Start           time.Time `json:"start,omitempty"`
+	End             time.Time `json:"end,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// WithEventIdentifiers runs the actions once for each event identifier
+func WithEventIdentifiers(eventIdentifiers ...string) Option {
+	return func(o *pluginManagerOptions) {
+		o.eventIdentifiers = eventIdentifiers
+	}
+}
+
+// EventSequence is an ordered list of event identifiers.
+// Integer ranges are kept as ranges and only expanded while the sequence is iterated.
+type EventSequence struct {
+	items []eventItem
+}
+
+// eventItem is a single event identifier or, when identifier is empty, an inclusive range of events
+type eventItem struct {
+	identifier string
+	from, to   int
+}
+
+func newEventSequence(eventIdentifiers ...string) EventSequence {
+	es := EventSequence{}
+	for _, event := range eventIdentifiers {
+		es.items = append(es.items, eventItem{identifier: event})
+	}
+	return es
+}
+
+// Len returns the number of events in the sequence
+func (es EventSequence) Len() int {
+	n := 0
+	for _, item := range es.items {
+		if item.identifier != "" {
+			n++
+		} else {
+			n += item.to - item.from + 1
+		}
+	}
+	return n
+}
+
+// First returns the first event identifier of the sequence or an empty string for an empty sequence
+func (es EventSequence) First() string {
+	first := ""
+	es.Each(func(event string) bool {
+		first = event
+		return false
+	})
+	return first
+}
+
+// Each calls fn with each event identifier in order until fn returns false
+func (es EventSequence) Each(fn func(event string) bool) {
+	for _, item := range es.items {
+		if item.identifier != "" {
+			if !fn(item.identifier) {
+				return
+			}
+			continue
+		}
+		for i := item.from; i <= item.to; i++ {
+			if !fn(strconv.Itoa(i)) {
+				return
+			}
+		}
+	}
+}
+
+// ParseEventIdentifiers parses an event identifier specification into an EventSequence.
+//
+// The specification is a comma separated list of integers and inclusive integer ranges,
+// for example "1-500", "1,2,3" or "1,4,10-20".  The boolean result is true only when the
+// specification is a list or contains a range, so a single identifier is not a sequence.
+func ParseEventIdentifiers(spec string) (EventSequence, bool) {
+	if !strings.ContainsAny(spec, ",-") {
+		return EventSequence{}, false
+	}
+	es := EventSequence{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if from, to, ok := strings.Cut(item, "-"); ok {
+			start, err1 := strconv.Atoi(strings.TrimSpace(from))
+			end, err2 := strconv.Atoi(strings.TrimSpace(to))
+			if err1 != nil || err2 != nil || start > end || start < 0 {
+				return EventSequence{}, false
+			}
+			es.items = append(es.items, eventItem{from: start, to: end})
+			continue
+		}
+		if _, err := strconv.Atoi(item); err != nil {
+			return EventSequence{}, false
+		}
+		es.items = append(es.items, eventItem{identifier: item})
+	}
+	return es, true
+}
+
+// EventIdentifiers returns the events processed by the manager.
+// A single event manager returns its EventIdentifier.
+func (pm *PluginManager) EventIdentifiers() EventSequence {
+	if pm.events.Len() == 0 {
+		return newEventSequence(pm.EventIdentifier)
+	}
+	return pm.events
+}
+
+// EventResults returns the per-event results of the most recent multi-event RunActions call
+func (pm *PluginManager) EventResults() []EventResult {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return slices.Clone(pm.eventResults)
+}
+
+func (pm *PluginManager) multiEvent() bool {
+	return pm.events.Len() > 1
+}
+
+// eventEnv returns the environment variables that identify the current event of a multi-event run
+func (pm *PluginManager) eventEnv() map[string]string {
+	if !pm.multiEvent() {
+		return nil
+	}
+	env := map[string]string{CcEventIdentifier: pm.EventIdentifier}
+	if _, err := strconv.Atoi(pm.EventIdentifier); err == nil {
+		env[CcEventNumber] = pm.EventIdentifier
+	}
+	return env
+}
+
+// setProcessEventEnv sets the current event in the process environment when the manager reads the
+// process environment, so runners see the event instead of the event identifier specification.
+// The returned function restores the previous values.
+func (pm *PluginManager) setProcessEventEnv() func() {
+	if pm.lookupEnv != nil {
+		return func() {}
+	}
+	env := pm.eventEnv()
+	previous := map[string]*string{}
+	for k, v := range env {
+		if old, ok := os.LookupEnv(k); ok {
+			previous[k] = &old
+		} else {
+			previous[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k, old := range previous {
+			if old == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *old)
+			}
+		}
+	}
+}
+
+// prepareEvent resets the payload to its unsubstituted state and resolves it for an event.
+// Store sessions with unchanged configuration are reused from the previous event.
+func (pm *PluginManager) prepareEvent(eventIdentifier string) error {
+	payload, err := clonePayload(pm.rawPayload)
+	if err != nil {
+		return fmt.Errorf("failed to copy payload for event %s: %w", eventIdentifier, err)
+	}
+	pm.EventIdentifier = eventIdentifier
+	return pm.loadPayload(payload)
+}
+
+// runEvents runs the actions once for each event and records the per-event results.
+// A failed event does not stop the following events.  Once ctx is cancelled no new events are started.
+// While an event runs CC_EVENT_IDENTIFIER (and CC_EVENT_NUMBER) in the process environment are the event.
+func (pm *PluginManager) runEvents(ctx context.Context) error {
+	pm.mu.Lock()
+	pm.eventResults = nil
+	pm.mu.Unlock()
+	var errs []error
+	started := 0
+	pm.events.Each(func(event string) bool {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%w: %d events from event %s not started: %w", ErrActionInterrupted, pm.events.Len()-started, event, context.Cause(ctx)))
+			return false
+		}
+		started++
+		result := EventResult{EventIdentifier: event, Start: time.Now()}
+		var err error
+		if event != pm.EventIdentifier {
+			err = pm.prepareEvent(event)
+		}
+		if err == nil {
+			restore := pm.setProcessEventEnv()
+			err = pm.runEventActions(ctx)
+			restore()
+		}
+		result.End = time.Now()
+		result.Status = SUCCEEDED
+		if err != nil {
+			result.Status = FAILED
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("event %s: %w", event, err))
+			pm.Logger.Error("event failed", "event", event, "error", err.Error())
+		} else {
+			pm.Logger.Info("event completed", "event", event)
+		}
+		pm.mu.Lock()
+		pm.eventResults = append(pm.eventResults, result)
+		pm.mu.Unlock()
+		return true
+	})
+	return errors.Join(errs...)
+}
@@ -0,0 +1,159 @@
+package cc
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEventIdentifiers(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected []string
+		ok       bool
+	}{
+		{"1-3", []string{"1", "2", "3"}, true},
+		{"1,4,10-12", []string{"1", "4", "10", "11", "12"}, true},
+		{"7", nil, false},
+		{"1,2,3", []string{"1", "2", "3"}, true},
+		{"1, a", nil, false},
+		{"a-b", nil, false},
+		{"5-1", nil, false},
+		{"", nil, false},
+	}
+	for _, test := range tests {
+		es, ok := ParseEventIdentifiers(test.spec)
+		events := eventList(es)
+		if ok != test.ok || !reflect.DeepEqual(events, test.expected) || es.Len() != len(events) {
+			t.Errorf("%q: expected %v %t, found %v %t", test.spec, test.expected, test.ok, events, ok)
+		}
+	}
+}
+
+func TestEventSequenceLazy(t *testing.T) {
+	es, ok := ParseEventIdentifiers("1-1000000000")
+	if !ok || es.Len() != 1000000000 || es.First() != "1" {
+		t.Fatalf("unexpected sequence: %d events starting at %s", es.Len(), es.First())
+	}
+}
+
+func eventList(es EventSequence) []string {
+	var events []string
+	es.Each(func(event string) bool {
+		events = append(events, event)
+		return true
+	})
+	return events
+}
+
+type eventTestAction struct {
+	ActionRunnerBase
+	events *[]string
+}
+
+func (a *eventTestAction) Run() error {
+	event, err := a.Action.Attributes.GetString("event")
+	if err != nil {
+		return err
+	}
+	*a.events = append(*a.events, event)
+	if event == "2" {
+		return errors.New("event failure")
+	}
+	return nil
+}
+
+func TestRunActionsMultiEvent(t *testing.T) {
+	var events []string
+	RegisterActionFactory("event-action", func(pm *PluginManager, action Action) (ActionRunner, error) {
+		return &eventTestAction{ActionRunnerBase: ActionRunnerBase{Action: action}, events: &events}, nil
+	})
+	payload := Payload{
+		Actions: []Action{{
+			Name:      "event-action",
+			IOManager: IOManager{Attributes: PayloadAttributes{"event": "{ENV::CC_EVENT_IDENTIFIER}"}},
+		}},
+	}
+	pm, err := initTestPluginManager(t, WithPayload(payload), WithEnv(map[string]string{CcEventIdentifier: "1-3"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pm.RunActions()
+	if err == nil || !strings.Contains(err.Error(), "event 2") {
+		t.Fatalf("expected the event 2 failure, found %v", err)
+	}
+	if !reflect.DeepEqual(events, []string{"1", "2", "3"}) {
+		t.Fatalf("expected each event to run with its own substitution, found %v: %v", events, err)
+	}
+	statuses := []Status{}
+	for _, result := range pm.EventResults() {
+		statuses = append(statuses, result.Status)
+	}
+	if !reflect.DeepEqual(statuses, []Status{SUCCEEDED, FAILED, SUCCEEDED}) {
+		t.Fatalf("unexpected event results: %+v", pm.EventResults())
+	}
+}
+
+type eventEnvTestAction struct {
+	ActionRunnerBase
+	events *[]string
+}
+
+func (a *eventEnvTestAction) Run() error {
+	event := os.Getenv(CcEventIdentifier)
+	*a.events = append(*a.events, event)
+	_, err := a.RunCommand(CommandInput{Name: "sh", Args: []string{"-c", `test "$CC_EVENT_IDENTIFIER" = "$1"`, "sh", event}})
+	return err
+}
+
+func TestRunActionsMultiEventEnv(t *testing.T) {
+	var events []string
+	RegisterActionFactory("event-env-action", func(pm *PluginManager, action Action) (ActionRunner, error) {
+		runner := &eventEnvTestAction{events: &events}
+		runner.bind(pm, action)
+		return runner, nil
+	})
+	t.Setenv(FsbRootPath, t.TempDir())
+	t.Setenv(CcEventIdentifier, "4,5-6")
+	store, err := NewFSBCcStore("test-manifest", "test-payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm, err := InitPluginManager(WithCcStore(store), WithPayload(Payload{Actions: []Action{{Name: "event-env-action"}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, []string{"4", "5", "6"}) {
+		t.Fatalf("expected each event in the process environment, found %v", events)
+	}
+	if event := os.Getenv(CcEventIdentifier); event != "4,5-6" {
+		t.Fatalf("expected the event identifier specification to be restored, found %s", event)
+	}
+}
+
+func TestDryRunMultiEvent(t *testing.T) {
+	payload := Payload{
+		Actions: []Action{{
+			Name:      "event-action",
+			IOManager: IOManager{Attributes: PayloadAttributes{"event": "{ENV::CC_EVENT_IDENTIFIER}"}},
+		}},
+	}
+	out := &strings.Builder{}
+	pm, err := initTestPluginManager(t, WithPayload(payload), WithEnv(map[string]string{CcEventIdentifier: "1-3"}), WithConfig(PluginManagerConfig{DryRun: true, DryRunOutput: out}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []string{"1", "2", "3"} {
+		if !strings.Contains(out.String(), "event "+event+"\n") || !strings.Contains(out.String(), "attribute event = "+event+"\n") {
+			t.Fatalf("expected the plan of event %s, found %s", event, out.String())
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
 
 	filestore "github.com/usace-cloud-compute/filesapi"
 )
@@ -41,6 +43,10 @@ func (fds *FileDataStore[T]) Get(path string, datapath string) (io.ReadCloser, e
 	return fds.fs.GetObject(fsgoi)
 }
 
+func (fds *FileDataStore[T]) GetObjectInfo(path string) (fs.FileInfo, error) {
+	return fds.fs.GetObjectInfo(filestore.PathConfig{Path: fds.root + "/" + path})
+}
+
 func (fds *FileDataStore[T]) GetFilestore() filestore.FileStore {
 	return fds.fs
 }
@@ -74,9 +80,14 @@ func (fds *FileDataStore[T]) GetSession() any {
 }
 
 func (fds *FileDataStore[T]) Connect(ds DataStore) (any, error) {
+	return fds.connectWithEnv(ds, os.LookupEnv)
+}
+
+// connectWithEnv connects to the store reading the store profile credentials with lookup
+func (fds *FileDataStore[T]) connectWithEnv(ds DataStore, lookup EnvLookup) (any, error) {
 	switch ds.StoreType {
 	case FSS3:
-		awsconfig := BuildS3Config(ds.DsProfile)
+		awsconfig := buildS3Config(lookup, ds.DsProfile)
 		fs, err := filestore.NewFileStore(awsconfig)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,155 @@
+package cc
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultLocalManifestId = "local-manifest"
+	defaultLocalPayloadId  = "local-payload"
+	defaultLocalStoreRoot  = "./cc_store"
+	localRunDataDir        = "data" //local root path (see PluginManager.RootPath) under the store root
+)
+
+// LocalRunInput configures a local plugin run backed by an FSBCcStore
+type LocalRunInput struct {
+	PayloadPath     string //path to the payload json file
+	EventIdentifier string
+	StoreRoot       string //root of the local FSB store. defaults to ./cc_store
+	ManifestId      string //defaults to local-manifest
+	PayloadId       string //defaults to local-payload
+	Config          PluginManagerConfig
+}
+
+// RunLocal runs the registered plugin actions against a payload file on the local file system.
+//
+// The payload is copied into the FSBCcStore layout (<StoreRoot>/<PayloadId>/payload) and the
+// plugin manager is initialized with an FSBCcStore rooted at StoreRoot and run.  Local action data,
+// workspaces and resource usage are kept under <StoreRoot>/data.  The process environment is not modified.
+func RunLocal(input LocalRunInput) error {
+	if input.PayloadPath == "" {
+		return errors.New("a payload file is required")
+	}
+	if input.StoreRoot == "" {
+		input.StoreRoot = defaultLocalStoreRoot
+	}
+	if input.ManifestId == "" {
+		input.ManifestId = defaultLocalManifestId
+	}
+	if input.PayloadId == "" {
+		input.PayloadId = defaultLocalPayloadId
+	}
+
+	storeRoot, err := filepath.Abs(input.StoreRoot)
+	if err != nil {
+		return err
+	}
+	err = stageLocalPayload(input.PayloadPath, filepath.Join(storeRoot, input.PayloadId))
+	if err != nil {
+		return err
+	}
+
+	dataRoot := filepath.Join(storeRoot, localRunDataDir)
+	if err := os.MkdirAll(dataRoot, 0755); err != nil {
+		return fmt.Errorf("failed to create root directory: %w", err)
+	}
+	//overlay the local run identity on the process environment so that
+	//ENV:: substitutions of the CC variables resolve to the local values
+	env := map[string]string{
+		CcStoreType:       string(FSB),
+		FsbRootPath:       storeRoot,
+		CcManifestId:      input.ManifestId,
+		CcPayloadId:       input.PayloadId,
+		CcEventIdentifier: input.EventIdentifier,
+	}
+	lookup := func(key string) (string, bool) {
+		if val, ok := env[key]; ok {
+			return val, true
+		}
+		return os.LookupEnv(key)
+	}
+	store := &FSBCcStore{dataRoot, storeRoot, input.ManifestId, input.PayloadId, FSB, lookup}
+
+	pm, err := InitPluginManager(
+		WithConfig(input.Config),
+		WithCcStore(store),
+		WithEnvLookup(lookup),
+	)
+	if err != nil {
+		return err
+	}
+	return pm.RunActions()
+}
+
+// stageLocalPayload validates a payload file and copies it into the payload directory of the local store
+func stageLocalPayload(payloadPath string, payloadDir string) error {
+	data, err := os.ReadFile(payloadPath)
+	if err != nil {
+		return fmt.Errorf("failed to read payload file: %w", err)
+	}
+	var payload Payload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return fmt.Errorf("invalid payload file %s: %w", payloadPath, err)
+	}
+	if err := os.MkdirAll(payloadDir, 0755); err != nil {
+		return fmt.Errorf("failed to create payload directory: %w", err)
+	}
+	return os.WriteFile(filepath.Join(payloadDir, payloadFileName), data, 0644)
+}
+
+// ParseLocalRunFlags parses command line arguments into a LocalRunInput
+func ParseLocalRunFlags(name string, args []string) (LocalRunInput, error) {
+	input := LocalRunInput{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&input.PayloadPath, "payload", "", "path to the payload json file (required)")
+	fs.StringVar(&input.EventIdentifier, "event", "1", "event identifier")
+	fs.StringVar(&input.StoreRoot, "root", defaultLocalStoreRoot, "root directory of the local cc store")
+	fs.StringVar(&input.ManifestId, "manifest", defaultLocalManifestId, "manifest id")
+	fs.StringVar(&input.PayloadId, "payload-id", defaultLocalPayloadId, "payload id")
+	fs.IntVar(&input.Config.MaxParallelActions, "parallel", 1, "maximum number of actions run at the same time")
+	fs.BoolVar(&input.Config.DryRun, "dry-run", false, "report the execution plan without running any action")
+	fs.BoolVar(&input.Config.EnableCheckpoints, "checkpoints", false, "skip actions completed in a previous run with unchanged inputs")
+	fs.BoolVar(&input.Config.ForceRerun, "force", false, "rerun actions completed in a previous run")
+	fs.BoolVar(&input.Config.AllowUnregisteredActions, "allow-unregistered", false, "skip payload actions without a registered runner")
+	err := fs.Parse(args)
+	if err == nil && input.PayloadPath == "" {
+		fs.Usage()
+		err = errors.New("the -payload flag is required")
+	}
+	return input, err
+}
+
+// RunLocalMain is a main function for running a plugin locally.
+// Plugin authors register their actions and call it from their own main:
+//
+//	func main() {
+//		cc.RegisterActionType[MyAction]("my-action")
+//		cc.RunLocalMain()
+//	}
+//
+// and run the plugin with: myplugin --payload ./payload.json --event 1
+//
+// A run interrupted by SIGINT or SIGTERM exits with ExitCodeInterrupted.
+func RunLocalMain() {
+	input, err := ParseLocalRunFlags(filepath.Base(os.Args[0]), os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	input.Config.DryRunOutput = os.Stdout
+	if err := RunLocal(input); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, ErrInterruptSignal) {
+			os.Exit(ExitCodeInterrupted)
+		}
+		os.Exit(1)
+	}
+}
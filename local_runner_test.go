@@ -0,0 +1,35 @@
+package cc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunLocal(t *testing.T) {
+	dir := t.TempDir()
+	payloadPath := filepath.Join(dir, "payload.json")
+	payload := `{"attributes":{},"stores":[],"inputs":[],"outputs":[],"actions":[{"name":"local-action"}]}`
+	if err := os.WriteFile(payloadPath, []byte(payload), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := 0
+	registerFlakyAction("local-action", 0, "", &attempts)
+	input, err := ParseLocalRunFlags("ccrun", []string{"-payload", payloadPath, "-event", "3", "-root", filepath.Join(dir, "store")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RunLocal(input); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected local-action to run once, found %d", attempts)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "store", defaultLocalManifestId, "run-summary-3.json")); err != nil {
+		t.Fatalf("expected a run summary in the local store: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "store", localRunDataDir)); err != nil {
+		t.Fatalf("expected the local root path under the store root: %s", err)
+	}
+}
@@ -0,0 +1,141 @@
+package cc
+
+import (
+	"os"
+	"strconv"
+)
+
+// EnvLookup retrieves the value of an environment variable in the same way as os.LookupEnv
+type EnvLookup func(key string) (string, bool)
+
+// getenv returns the value of key or an empty string when it is not set
+func (lookup EnvLookup) getenv(key string) string {
+	val, _ := lookup(key)
+	return val
+}
+
+// lookupKey looks up key, reading the process environment when the lookup is nil
+func (lookup EnvLookup) lookupKey(key string) (string, bool) {
+	if lookup == nil {
+		return os.LookupEnv(key)
+	}
+	return lookup(key)
+}
+
+type pluginManagerOptions struct {
+	store             CcStore
+	payload           *Payload
+	logger            *CcLogger
+	lookupEnv         EnvLookup
+	eventIdentifier   *string
+	eventIdentifiers  []string
+	storeRegistry     DataStoreTypeRegistryMap
+	pluginDefinition  *PluginDefinition
+	config            PluginManagerConfig
+	returnOnInterrupt bool
+}
+
+// Option configures InitPluginManager
+type Option func(*pluginManagerOptions)
+
+// WithCcStore uses store in place of the store created by NewCcStore
+func WithCcStore(store CcStore) Option {
+	return func(o *pluginManagerOptions) {
+		o.store = store
+	}
+}
+
+// WithPayload uses a pre-loaded payload instead of retrieving the payload from the CcStore
+func WithPayload(payload Payload) Option {
+	return func(o *pluginManagerOptions) {
+		o.payload = &payload
+	}
+}
+
+// WithLogger uses logger in place of a new CcLogger
+func WithLogger(logger *CcLogger) Option {
+	return func(o *pluginManagerOptions) {
+		o.logger = logger
+	}
+}
+
+// WithEnvLookup reads the CC environment (ids, ENV:: substitutions and switches) from lookup instead of the process environment
+func WithEnvLookup(lookup EnvLookup) Option {
+	return func(o *pluginManagerOptions) {
+		o.lookupEnv = lookup
+	}
+}
+
+// WithEnv reads the CC environment from a map instead of the process environment
+func WithEnv(env map[string]string) Option {
+	return WithEnvLookup(func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	})
+}
+
+// WithEventIdentifier sets the event identifier instead of reading CC_EVENT_IDENTIFIER
+func WithEventIdentifier(eventIdentifier string) Option {
+	return func(o *pluginManagerOptions) {
+		o.eventIdentifier = &eventIdentifier
+	}
+}
+
+// WithStoreRegistry uses registry to create the payload data stores instead of the DataStoreTypeRegistry
+func WithStoreRegistry(registry DataStoreTypeRegistryMap) Option {
+	return func(o *pluginManagerOptions) {
+		o.storeRegistry = registry
+	}
+}
+
+// WithConfig sets the plugin manager configuration
+func WithConfig(config PluginManagerConfig) Option {
+	return func(o *pluginManagerOptions) {
+		o.config = config
+	}
+}
+
+// WithReturnOnInterrupt makes RunActions return an error wrapping ErrInterruptSignal after SIGINT or SIGTERM
+// instead of exiting the process with ExitCodeInterrupted, for callers that run more work after the actions
+func WithReturnOnInterrupt() Option {
+	return func(o *pluginManagerOptions) {
+		o.returnOnInterrupt = true
+	}
+}
+
+// getenv returns the value of an environment variable using the manager env lookup
+func (pm *PluginManager) getenv(key string) string {
+	val, _ := pm.envLookup()(key)
+	return val
+}
+
+// envLookup returns the manager env lookup.
+// In a multi-event run CC_EVENT_IDENTIFIER (and CC_EVENT_NUMBER for numeric events)
+// resolve to the event currently being processed.
+func (pm *PluginManager) envLookup() EnvLookup {
+	lookup := pm.lookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	if !pm.multiEvent() {
+		return lookup
+	}
+	return func(key string) (string, bool) {
+		switch key {
+		case CcEventIdentifier:
+			return pm.EventIdentifier, true
+		case CcEventNumber:
+			if _, err := strconv.Atoi(pm.EventIdentifier); err == nil {
+				return pm.EventIdentifier, true
+			}
+		}
+		return lookup(key)
+	}
+}
+
+func (pm *PluginManager) storeTypeRegistry() DataStoreTypeRegistryMap {
+	if pm.storeRegistry == nil {
+		return DataStoreTypeRegistry
+	}
+	return pm.storeRegistry
+}
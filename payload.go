@@ -2,6 +2,7 @@ package cc
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -24,11 +25,25 @@ type Payload struct {
 	Actions []Action `json:"actions"`
 }
 
+// clonePayload returns a deep copy of a payload.
+// Store sessions are not part of the copy.
+func clonePayload(p Payload) (Payload, error) {
+	var clone Payload
+	data, err := json.Marshal(p)
+	if err != nil {
+		return clone, err
+	}
+	err = json.Unmarshal(data, &clone)
+	return clone, err
+}
+
 type Action struct {
 	IOManager
-	Type        string `json:"type,omitempty"`
-	Description string `json:"description,omitempty"`
-	Name        string `json:"name,omitempty"`
+	Type        string       `json:"type,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	DependsOn   []string     `json:"depends_on,omitempty"`
+	Retry       *RetryPolicy `json:"retry,omitempty"`
 }
 
 // -----------------------------------------------
@@ -84,6 +99,7 @@ type IOManager struct {
 	Inputs     []DataSource      `json:"inputs"`
 	Outputs    []DataSource      `json:"outputs"`
 	parent     *IOManager
+	writes     *writeTracker
 }
 
 type GetDsInput struct {
@@ -114,10 +130,20 @@ func (im *IOManager) SetParent(iom *IOManager) {
 	im.parent = iom
 }
 
+// writeTracker returns the write tracker of the IOManager or its parents
+func (im *IOManager) writeTracker() *writeTracker {
+	if im.writes == nil && im.parent != nil {
+		return im.parent.writeTracker()
+	}
+	return im.writes
+}
+
+// GetStore returns a reference to the named store in the IOManager or its parents.
+// The reference points into the payload so session changes are seen by every caller.
 func (im *IOManager) GetStore(name string) (*DataStore, error) {
-	for _, store := range im.Stores {
-		if store.Name == name {
-			return &store, nil
+	for i := range im.Stores {
+		if im.Stores[i].Name == name {
+			return &im.Stores[i], nil
 		}
 	}
 	if im.parent != nil {
@@ -149,6 +175,29 @@ func (im *IOManager) GetDataSource(input GetDsInput) (DataSource, error) {
 	return DataSource{}, fmt.Errorf("data source %s not found", input.DsName)
 }
 
+// GetDataSourceRef returns a reference to a data source in the IOManager or its parents.
+// Unlike GetDataSource the result is not a copy and must be treated as read only while actions run.
+func (im *IOManager) GetDataSourceRef(input GetDsInput) (*DataSource, error) {
+	if input.DsIoType == DataSourceInput || input.DsIoType == DataSourceAll {
+		for i := range im.Inputs {
+			if im.Inputs[i].Name == input.DsName {
+				return &im.Inputs[i], nil
+			}
+		}
+	}
+	if input.DsIoType == DataSourceOutput || input.DsIoType == DataSourceAll {
+		for i := range im.Outputs {
+			if im.Outputs[i].Name == input.DsName {
+				return &im.Outputs[i], nil
+			}
+		}
+	}
+	if im.parent != nil {
+		return im.parent.GetDataSourceRef(input)
+	}
+	return nil, fmt.Errorf("data source %s not found", input.DsName)
+}
+
 func (im *IOManager) GetInputDataSource(name string) (DataSource, error) {
 	return im.GetDataSource(GetDsInput{DataSourceInput, name})
 }
@@ -196,7 +245,7 @@ func (im *IOManager) GetReader(input DataSourceOpInput) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
-	if readerStore, ok := dataStore.Session.(StoreReader); ok {
+	if readerStore, ok := dataStore.session().(StoreReader); ok {
 		path := dataSource.Paths[input.PathKey]
 		if len(input.TemplateVars) > 0 {
 			path = templateVarSubstitution(path, input.TemplateVars)
@@ -222,6 +271,11 @@ func (im *IOManager) Get(input DataSourceOpInput) ([]byte, error) {
 }
 
 func (im *IOManager) Put(input PutOpInput) (int, error) {
+	if err := im.writeTracker().begin(); err != nil {
+		return 0, err
+	}
+	defer im.writeTracker().end()
+
 	var err error
 	var ds DataSource
 
@@ -237,7 +291,7 @@ func (im *IOManager) Put(input PutOpInput) (int, error) {
 		return 0, err
 	}
 
-	if writer, ok := store.Session.(StoreWriter); ok {
+	if writer, ok := store.session().(StoreWriter); ok {
 		if path, ok := ds.Paths[input.PathKey]; ok {
 			if len(input.TemplateVars) > 0 {
 				path = templateVarSubstitution(path, input.TemplateVars)
@@ -257,6 +311,11 @@ func (im *IOManager) Put(input PutOpInput) (int, error) {
 }
 
 func (im *IOManager) Copy(src DataSourceOpInput, dest DataSourceOpInput) error {
+	if err := im.writeTracker().begin(); err != nil {
+		return err
+	}
+	defer im.writeTracker().end()
+
 	srcds, err := im.GetOutputDataSource(src.DataSourceName)
 	if err != nil {
 		return err
@@ -277,8 +336,8 @@ func (im *IOManager) Copy(src DataSourceOpInput, dest DataSourceOpInput) error {
 		return err
 	}
 
-	if srcReader, ok := srcstore.Session.(StoreReader); ok {
-		if destwriter, ok := deststore.Session.(StoreWriter); ok {
+	if srcReader, ok := srcstore.session().(StoreReader); ok {
+		if destwriter, ok := deststore.session().(StoreWriter); ok {
 
 			//get the reader
 			srcpath := srcds.Paths[src.PathKey]
@@ -327,7 +386,7 @@ func (im *IOManager) CopyFileToLocal(input CopyToLocalInput) error {
 		return fmt.Errorf("pathkey '%s' not found", input.PathKey)
 	}
 
-	ifds, ok := store.Session.(FileDataStoreInterface)
+	ifds, ok := store.session().(FileDataStoreInterface)
 	if !ok {
 		return fmt.Errorf("data store %s is not a filestore", input.DsName)
 	}
@@ -407,6 +466,11 @@ type CopyFileToRemoteInput struct {
 }
 
 func (im *IOManager) CopyFileToRemote(input CopyFileToRemoteInput) error {
+	if err := im.writeTracker().begin(); err != nil {
+		return err
+	}
+	defer im.writeTracker().end()
+
 	storeName := input.RemoteStoreName
 	path := input.RemotePath
 	if storeName == "" {
@@ -439,7 +503,7 @@ func (im *IOManager) CopyFileToRemote(input CopyFileToRemoteInput) error {
 		return err
 	}
 
-	if ifds, ok := store.Session.(FileDataStoreInterface); ok {
+	if ifds, ok := store.session().(FileDataStoreInterface); ok {
 		fullRemotePath := ifds.GetAbsolutePath(path)
 		fs := ifds.GetFilestore()
 		if info.IsDir() {
@@ -480,9 +544,9 @@ func writeFileToRemote(fs filesapi.FileStore, localPath string, remoteAbsolutePa
 }
 
 func GetStoreAs[T any](mgr *IOManager, name string) (T, error) {
-	for _, s := range mgr.Stores {
-		if s.Name == name {
-			if t, ok := s.Session.(T); ok {
+	for i := range mgr.Stores {
+		if s := &mgr.Stores[i]; s.Name == name {
+			if t, ok := s.session().(T); ok {
 				return t, nil
 			} else {
 				return t, fmt.Errorf("invalid store type: %s", s.StoreType)
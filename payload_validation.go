@@ -0,0 +1,83 @@
+package cc
+
+// Validate checks the structure of the IOManager and returns a ValidationError listing every problem:
+//   - store and data source names are set and unique
+//   - store types are registered in the DataStoreTypeRegistry
+//   - every data source store name refers to a store in the IOManager or its parent
+func (im *IOManager) Validate() error {
+	registerStoreTypes()
+	verr := ValidationError{}
+	im.validate("$", im.parent, DataStoreTypeRegistry, &verr)
+	return verr.err()
+}
+
+// Validate checks the structure of the payload IOManager and of every action IOManager.
+// Action data sources can refer to stores declared on the payload.
+func (p *Payload) Validate() error {
+	registerStoreTypes()
+	return p.validate(DataStoreTypeRegistry)
+}
+
+func (p *Payload) validate(registry DataStoreTypeRegistryMap) error {
+	verr := ValidationError{}
+	p.IOManager.validate("$", nil, registry, &verr)
+	for i := range p.Actions {
+		path := jsonPathIndex("$.actions", i)
+		if p.Actions[i].Name == "" {
+			verr.add(jsonPathField(path, "name"), "is required")
+		}
+		p.Actions[i].IOManager.validate(path, &p.IOManager, registry, &verr)
+	}
+	return verr.err()
+}
+
+func (im *IOManager) validate(path string, parent *IOManager, registry DataStoreTypeRegistryMap, verr *ValidationError) {
+	storeNames := map[string]bool{}
+	for i, store := range im.Stores {
+		storePath := jsonPathIndex(jsonPathField(path, "stores"), i)
+		validateName(verr, storePath, "store", store.Name, storeNames)
+		if store.StoreType == "" {
+			verr.add(jsonPathField(storePath, "store_type"), "is required")
+		} else if _, ok := registry[store.StoreType]; !ok {
+			verr.add(jsonPathField(storePath, "store_type"), "store type %s is not registered", store.StoreType)
+		}
+	}
+	im.validateDataSources(jsonPathField(path, "inputs"), "input", im.Inputs, parent, verr)
+	im.validateDataSources(jsonPathField(path, "outputs"), "output", im.Outputs, parent, verr)
+}
+
+func (im *IOManager) validateDataSources(path string, ioType string, sources []DataSource, parent *IOManager, verr *ValidationError) {
+	names := map[string]bool{}
+	for i, ds := range sources {
+		dsPath := jsonPathIndex(path, i)
+		validateName(verr, dsPath, ioType+" data source", ds.Name, names)
+		if ds.StoreName == "" {
+			verr.add(jsonPathField(dsPath, "store_name"), "is required")
+		} else if !im.hasStore(ds.StoreName, parent) {
+			verr.add(jsonPathField(dsPath, "store_name"), "store %s is not declared", ds.StoreName)
+		}
+	}
+}
+
+// hasStore reports whether a store is declared on the IOManager or its ancestors
+func (im *IOManager) hasStore(name string, parent *IOManager) bool {
+	for _, store := range im.Stores {
+		if store.Name == name {
+			return true
+		}
+	}
+	if parent == nil {
+		return false
+	}
+	return parent.hasStore(name, parent.parent)
+}
+
+func validateName(verr *ValidationError, path string, kind string, name string, seen map[string]bool) {
+	switch {
+	case name == "":
+		verr.add(jsonPathField(path, "name"), "is required")
+	case seen[name]:
+		verr.add(jsonPathField(path, "name"), "duplicate %s name %s", kind, name)
+	}
+	seen[name] = true
+}
@@ -0,0 +1,53 @@
+package cc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPayloadValidate(t *testing.T) {
+	payload := Payload{
+		IOManager: IOManager{
+			Stores: []DataStore{
+				{Name: "local", StoreType: FSB},
+				{Name: "local", StoreType: "MISSING"},
+			},
+			Inputs: []DataSource{
+				{Name: "terrain", StoreName: "local", Paths: map[string]string{"default": "terrain.tif"}},
+				{Name: "terrain", StoreName: "remote", Paths: map[string]string{"default": "terrain2.tif"}},
+			},
+		},
+		Actions: []Action{{
+			Name: "compute",
+			IOManager: IOManager{
+				Outputs: []DataSource{
+					{Name: "results", StoreName: "local", Paths: map[string]string{"hdf": "p04.hdf"}, DataPaths: map[string]string{"depth": "/results/depth"}},
+					{StoreName: "local", Paths: map[string]string{"log": "p04.log"}},
+				},
+			},
+		}},
+	}
+	err := payload.Validate()
+	verr := &ValidationError{}
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, found %v", err)
+	}
+	expected := []Violation{
+		{"$.stores[1].name", "duplicate store name local"},
+		{"$.stores[1].store_type", "store type MISSING is not registered"},
+		{"$.inputs[1].name", "duplicate input data source name terrain"},
+		{"$.inputs[1].store_name", "store remote is not declared"},
+		{"$.actions[0].outputs[1].name", "is required"},
+	}
+	if !reflect.DeepEqual(verr.Violations, expected) {
+		t.Fatalf("unexpected violations:\n%s", verr)
+	}
+
+	payload.Stores = payload.Stores[:1]
+	payload.Inputs = payload.Inputs[:1]
+	payload.Actions[0].Outputs = payload.Actions[0].Outputs[:1]
+	if err := payload.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
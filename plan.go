@@ -0,0 +1,276 @@
+package cc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CcDryRun is the environment variable that switches RunActions into plan mode.
+// When set to a true value RunActions reports the execution plan without running any action.
+const CcDryRun = "CC_DRY_RUN"
+
+// ExecutionPlan describes what a payload will do after substitution
+type ExecutionPlan struct {
+	Manifest        string           `json:"manifest"`
+	Payload         string           `json:"payload"`
+	EventIdentifier string           `json:"event_identifier,omitempty"`
+	Attributes      map[string]any   `json:"attributes,omitempty"`
+	Stores          []StorePlan      `json:"stores"`
+	Inputs          []DataSourcePlan `json:"inputs,omitempty"`
+	Outputs         []DataSourcePlan `json:"outputs,omitempty"`
+	Actions         []ActionPlan     `json:"actions"`
+}
+
+// StorePlan reports a data store and whether a connection could be made to it
+type StorePlan struct {
+	Name       string         `json:"name"`
+	Scope      string         `json:"scope"`
+	StoreType  StoreType      `json:"store_type"`
+	Parameters map[string]any `json:"params,omitempty"`
+	Connected  bool           `json:"connected"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// DataSourcePlan reports a data source with the absolute path of each path key
+type DataSourcePlan struct {
+	Name      string            `json:"name"`
+	StoreName string            `json:"store_name"`
+	Paths     map[string]string `json:"paths,omitempty"`
+	DataPaths map[string]string `json:"data_paths,omitempty"`
+	Errors    []string          `json:"errors,omitempty"`
+}
+
+// ActionPlan reports the resolved attributes of an action and the data it reads and writes
+type ActionPlan struct {
+	Name            string           `json:"name"`
+	Type            string           `json:"type,omitempty"`
+	DependsOn       []string         `json:"depends_on,omitempty"`
+	Registered      bool             `json:"registered"`
+	ValidationError string           `json:"validation_error,omitempty"`
+	Attributes      map[string]any   `json:"attributes,omitempty"`
+	Reads           []DataSourcePlan `json:"reads,omitempty"`
+	Writes          []DataSourcePlan `json:"writes,omitempty"`
+}
+
+// Plan resolves the payload of the current event into an ExecutionPlan without running any action.
+// Every store is checked with a new connection that is closed after the check, and every
+// action runner is created and validated.
+func (pm *PluginManager) Plan() (*ExecutionPlan, error) {
+	plan := ExecutionPlan{
+		Manifest:        pm.manifestId,
+		Payload:         pm.payloadId,
+		EventIdentifier: pm.EventIdentifier,
+		Attributes:      pm.Attributes,
+		Stores:          pm.planStores("payload", pm.Stores),
+		Inputs:          planDataSources(&pm.IOManager, pm.Inputs),
+		Outputs:         planDataSources(&pm.IOManager, pm.Outputs),
+	}
+
+	for i, action := range pm.Actions {
+		plan.Stores = append(plan.Stores, pm.planStores(action.Name, action.Stores)...)
+		actionPlan := ActionPlan{
+			Name:       action.Name,
+			Type:       action.Type,
+			DependsOn:  action.DependsOn,
+			Attributes: action.Attributes,
+			Reads:      planDataSources(&action.IOManager, action.Inputs),
+			Writes:     planDataSources(&action.IOManager, action.Outputs),
+		}
+		runner, ok, err := pm.newActionRunner(action)
+		actionPlan.Registered = ok
+		if err != nil {
+			actionPlan.ValidationError = err.Error()
+		} else if ok {
+			if err := validateActionRunner(i, action, runner); err != nil {
+				actionPlan.ValidationError = err.Error()
+			}
+		}
+		plan.Actions = append(plan.Actions, actionPlan)
+	}
+
+	if _, err := newActionGraph(pm.Actions); err != nil {
+		return &plan, err
+	}
+	return &plan, nil
+}
+
+func (pm *PluginManager) planStores(scope string, stores []DataStore) []StorePlan {
+	plans := []StorePlan{}
+	for _, ds := range stores {
+		sp := StorePlan{
+			Name:       ds.Name,
+			Scope:      scope,
+			StoreType:  ds.StoreType,
+			Parameters: ds.Parameters,
+		}
+		err := pm.checkStoreConnection(ds)
+		sp.Connected = err == nil
+		if err != nil {
+			sp.Error = err.Error()
+		}
+		plans = append(plans, sp)
+	}
+	return plans
+}
+
+// checkStoreConnection opens a new connection to a data store and closes it
+func (pm *PluginManager) checkStoreConnection(ds DataStore) error {
+	newInstance, err := pm.storeTypeRegistry().New(ds.StoreType)
+	if err != nil {
+		return err
+	}
+	cds, ok := newInstance.(ConnectionDataStore)
+	if !ok {
+		return nil
+	}
+	conn, err := pm.connect(cds, ds)
+	if err != nil {
+		return err
+	}
+	if closer, ok := conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func planDataSources(iom *IOManager, sources []DataSource) []DataSourcePlan {
+	plans := []DataSourcePlan{}
+	for _, ds := range sources {
+		dsp := DataSourcePlan{
+			Name:      ds.Name,
+			StoreName: ds.StoreName,
+			Paths:     make(map[string]string),
+			DataPaths: ds.DataPaths,
+		}
+		for _, key := range sortedKeys(ds.Paths) {
+			path, err := iom.GetAbsolutePath(ds.StoreName, ds.Name, key)
+			if err != nil {
+				dsp.Errors = append(dsp.Errors, fmt.Sprintf("path %s: %s", key, err))
+				path = ds.Paths[key]
+			}
+			dsp.Paths[key] = path
+		}
+		plans = append(plans, dsp)
+	}
+	return plans
+}
+
+// JSON returns the indented json representation of the plan
+func (p ExecutionPlan) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// String returns a human readable representation of the plan
+func (p ExecutionPlan) String() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "Execution plan for manifest %s, payload %s, event %s\n", p.Manifest, p.Payload, p.EventIdentifier)
+	b.WriteString("Stores:\n")
+	for _, s := range p.Stores {
+		status := "connected"
+		if !s.Connected {
+			status = "NOT CONNECTED: " + s.Error
+		}
+		fmt.Fprintf(&b, "  %s (%s, %s): %s\n", s.Name, s.StoreType, s.Scope, status)
+	}
+	writeDataSources(&b, "  ", "Payload inputs", p.Inputs)
+	writeDataSources(&b, "  ", "Payload outputs", p.Outputs)
+	b.WriteString("Actions:\n")
+	for i, a := range p.Actions {
+		fmt.Fprintf(&b, "  %d. %s", i+1, a.Name)
+		if len(a.DependsOn) > 0 {
+			fmt.Fprintf(&b, " (depends on %s)", strings.Join(a.DependsOn, ", "))
+		}
+		if !a.Registered {
+			b.WriteString(" [UNREGISTERED]")
+		}
+		b.WriteString("\n")
+		if a.ValidationError != "" {
+			fmt.Fprintf(&b, "      validation error: %s\n", a.ValidationError)
+		}
+		for _, k := range sortedKeys(a.Attributes) {
+			fmt.Fprintf(&b, "      attribute %s = %v\n", k, a.Attributes[k])
+		}
+		writeDataSources(&b, "      ", "Reads", a.Reads)
+		writeDataSources(&b, "      ", "Writes", a.Writes)
+	}
+	return b.String()
+}
+
+func writeDataSources(b *strings.Builder, indent string, title string, sources []DataSourcePlan) {
+	if len(sources) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s%s:\n", indent, title)
+	for _, ds := range sources {
+		fmt.Fprintf(b, "%s  %s (store %s)\n", indent, ds.Name, ds.StoreName)
+		for _, k := range sortedKeys(ds.Paths) {
+			fmt.Fprintf(b, "%s    %s: %s\n", indent, k, ds.Paths[k])
+		}
+		for _, k := range sortedKeys(ds.DataPaths) {
+			fmt.Fprintf(b, "%s    %s (data path): %s\n", indent, k, ds.DataPaths[k])
+		}
+		for _, e := range ds.Errors {
+			fmt.Fprintf(b, "%s    error: %s\n", indent, e)
+		}
+	}
+}
+
+// dryRun reports the execution plan as a json log record and as text on PluginManagerConfig.DryRunOutput.
+// A multi-event manager reports the plan of every event.
+func (pm *PluginManager) dryRun() error {
+	if !pm.multiEvent() {
+		return pm.dryRunEvent()
+	}
+	var errs []error
+	pm.events.Each(func(event string) bool {
+		var err error
+		if event != pm.EventIdentifier {
+			err = pm.prepareEvent(event)
+		}
+		if err == nil {
+			err = pm.dryRunEvent()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("event %s: %w", event, err))
+		}
+		return true
+	})
+	return errors.Join(errs...)
+}
+
+// dryRunEvent reports the execution plan of the current event
+func (pm *PluginManager) dryRunEvent() error {
+	plan, err := pm.Plan()
+	if plan != nil {
+		pm.Logger.Info("execution plan", "plan", plan)
+		if pm.config.DryRunOutput != nil {
+			if _, werr := io.WriteString(pm.config.DryRunOutput, plan.String()); werr != nil {
+				return errors.Join(err, werr)
+			}
+		}
+	}
+	return err
+}
+
+// dryRunEnabled reports whether dry run mode is set in the config or with CC_DRY_RUN
+func (pm *PluginManager) dryRunEnabled() bool {
+	if pm.config.DryRun {
+		return true
+	}
+	enabled, _ := strconv.ParseBool(pm.getenv(CcDryRun))
+	return enabled
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,121 @@
+package cc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	registerStoreTypes()
+	calls := []string{}
+	registerLifecycleAction("plan-action", &calls, nil, nil)
+	pm := newTestPluginManager(Action{
+		Name: "plan-action",
+		IOManager: IOManager{
+			Attributes: PayloadAttributes{"plan": "04"},
+			Inputs: []DataSource{{
+				Name:      "terrain",
+				StoreName: "local",
+				Paths:     map[string]string{"default": "terrain/muncie.tif"},
+			}},
+			Outputs: []DataSource{{
+				Name:      "results",
+				StoreName: "local",
+				Paths:     map[string]string{"default": "results/p04.hdf"},
+			}},
+		},
+	})
+	pm.Stores = []DataStore{{Name: "local", StoreType: FSB, Parameters: PayloadAttributes{"root": "/data/model"}}}
+	pm.Actions[0].SetParent(&pm.IOManager)
+
+	plan, err := pm.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Stores) != 1 || !plan.Stores[0].Connected {
+		t.Fatalf("expected a connected store: %+v", plan.Stores)
+	}
+	action := plan.Actions[0]
+	if !action.Registered || action.Reads[0].Paths["default"] != "/data/model/terrain/muncie.tif" || action.Writes[0].Paths["default"] != "/data/model/results/p04.hdf" {
+		t.Fatalf("unexpected action plan: %+v", action)
+	}
+	if !strings.Contains(plan.String(), "/data/model/results/p04.hdf") {
+		t.Fatalf("expected the output path in the plan text:\n%s", plan)
+	}
+
+	calls = calls[:0]
+	out := &bytes.Buffer{}
+	pm.config.DryRun = true
+	pm.config.DryRunOutput = out
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0] != "plan-action:validate" {
+		t.Fatalf("dry run should only validate actions: %v", calls)
+	}
+	if out.String() != plan.String() {
+		t.Fatalf("expected the plan text on the dry run output, found:\n%s", out)
+	}
+}
+
+// probeStore fails to connect when the "fail" parameter is set and counts its open connections
+type probeStore struct{}
+
+var probeConnections atomic.Int32
+
+type probeSession struct{}
+
+func (ps *probeSession) Close() error {
+	probeConnections.Add(-1)
+	return nil
+}
+
+func (s *probeStore) Connect(ds DataStore) (any, error) {
+	if ds.Parameters.GetStringOrDefault("fail", "") != "" {
+		return nil, errors.New("store is unreachable")
+	}
+	probeConnections.Add(1)
+	return &probeSession{}, nil
+}
+
+func (s *probeStore) GetSession() any {
+	return nil
+}
+
+func TestDryRunConnectionFailure(t *testing.T) {
+	registry := DataStoreTypeRegistryMap{}
+	registry.Register("PROBE", probeStore{})
+	payload := Payload{
+		IOManager: IOManager{
+			Stores: []DataStore{
+				{Name: "reachable", StoreType: "PROBE"},
+				{Name: "unreachable", StoreType: "PROBE", Parameters: PayloadAttributes{"fail": "true"}},
+			},
+		},
+		Actions: []Action{{Name: "plan-action"}},
+	}
+
+	_, err := initTestPluginManager(t, WithPayload(payload), WithStoreRegistry(registry))
+	if err == nil || !strings.Contains(err.Error(), "store is unreachable") {
+		t.Fatalf("expected the connection failure to abort initialization, found %v", err)
+	}
+
+	pm, err := initTestPluginManager(t, WithPayload(payload), WithStoreRegistry(registry), WithConfig(PluginManagerConfig{DryRun: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := probeConnections.Load()
+	plan, err := pm.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Stores) != 2 || !plan.Stores[0].Connected || plan.Stores[1].Connected || plan.Stores[1].Error != "store is unreachable" {
+		t.Fatalf("expected the unreachable store in the plan: %+v", plan.Stores)
+	}
+	if probeConnections.Load() != open {
+		t.Fatalf("expected the probe connections to be closed, %d left open", probeConnections.Load()-open)
+	}
+}
@@ -0,0 +1,171 @@
+package cc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PluginDefinition describes the actions a plugin offers and the attributes and data sources they need.
+// It is loaded from CC_PLUGIN_DEFINITION (inline JSON or the path of a JSON file) or registered by the
+// plugin with RegisterPluginDefinition, typically from a go:embed file.  When a definition is found,
+// InitPluginManager checks the payload against it and fails before any action runs.
+type PluginDefinition struct {
+	Name        string             `json:"name,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Actions     []ActionDefinition `json:"actions"`
+}
+
+// ActionDefinition describes an action offered by a plugin
+type ActionDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Attributes  []string               `json:"attributes,omitempty"` //attributes the action requires
+	Inputs      []DataSourceDefinition `json:"inputs,omitempty"`
+	Outputs     []DataSourceDefinition `json:"outputs,omitempty"`
+}
+
+// DataSourceDefinition describes an input or output data source of an action.
+// The data source can be declared on the action or on the payload.
+type DataSourceDefinition struct {
+	Name      string   `json:"name"`
+	Optional  bool     `json:"optional,omitempty"`
+	Paths     []string `json:"paths,omitempty"`      //required path keys
+	DataPaths []string `json:"data_paths,omitempty"` //required data path keys
+}
+
+var registeredPluginDefinition *PluginDefinition
+
+// RegisterPluginDefinition sets the plugin definition compiled into the plugin.
+// A definition in CC_PLUGIN_DEFINITION takes precedence.
+func RegisterPluginDefinition(def PluginDefinition) {
+	registeredPluginDefinition = &def
+}
+
+// RegisterPluginDefinitionJSON registers a plugin definition document, for example one embedded with go:embed
+func RegisterPluginDefinitionJSON(data []byte) error {
+	def, err := ParsePluginDefinition(data)
+	if err != nil {
+		return err
+	}
+	RegisterPluginDefinition(*def)
+	return nil
+}
+
+// WithPluginDefinition checks the payload against def instead of the registered or CC_PLUGIN_DEFINITION definition
+func WithPluginDefinition(def PluginDefinition) Option {
+	return func(o *pluginManagerOptions) {
+		o.pluginDefinition = &def
+	}
+}
+
+// ParsePluginDefinition parses a plugin definition document
+func ParsePluginDefinition(data []byte) (*PluginDefinition, error) {
+	def := PluginDefinition{}
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("invalid plugin definition: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, action := range def.Actions {
+		if action.Name == "" {
+			return nil, fmt.Errorf("invalid plugin definition: action without a name")
+		}
+		if seen[action.Name] {
+			return nil, fmt.Errorf("invalid plugin definition: duplicate action %s", action.Name)
+		}
+		seen[action.Name] = true
+	}
+	return &def, nil
+}
+
+// PluginDefinition returns the definition the payload was checked against or nil if the plugin has none
+func (pm *PluginManager) PluginDefinition() *PluginDefinition {
+	return pm.definition
+}
+
+// loadPluginDefinition resolves the plugin definition from CC_PLUGIN_DEFINITION or the registered definition
+func (pm *PluginManager) loadPluginDefinition() (*PluginDefinition, error) {
+	value := strings.TrimSpace(pm.getenv(CcPluginDefinition))
+	if value == "" {
+		return registeredPluginDefinition, nil
+	}
+	data := []byte(value)
+	if !strings.HasPrefix(value, "{") {
+		var err error
+		data, err = os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the plugin definition %s: %w", value, err)
+		}
+	}
+	return ParsePluginDefinition(data)
+}
+
+// Action returns the definition of the named action
+func (def *PluginDefinition) Action(name string) (ActionDefinition, bool) {
+	for _, action := range def.Actions {
+		if action.Name == name {
+			return action, true
+		}
+	}
+	return ActionDefinition{}, false
+}
+
+// CheckPayload checks that every payload action is defined by the plugin and has the
+// attributes, data sources and path keys its definition requires.
+// All of the violations are returned in a ValidationError.
+func (def *PluginDefinition) CheckPayload(payload *Payload) error {
+	verr := ValidationError{}
+	for i, action := range payload.Actions {
+		path := jsonPathIndex("$.actions", i)
+		actionDef, ok := def.Action(action.Name)
+		if !ok {
+			verr.add(jsonPathField(path, "name"), "action %s is not defined by the plugin", action.Name)
+			continue
+		}
+		for _, attr := range actionDef.Attributes {
+			if _, ok := action.Attributes[attr]; !ok {
+				verr.add(jsonPathField(jsonPathField(path, "attributes"), attr), "is required")
+			}
+		}
+		checkDataSources(&verr, i, "inputs", action.Inputs, payload.Inputs, actionDef.Inputs)
+		checkDataSources(&verr, i, "outputs", action.Outputs, payload.Outputs, actionDef.Outputs)
+	}
+	return verr.err()
+}
+
+// checkDataSources finds each defined data source on the action or the payload and checks its path keys
+func checkDataSources(verr *ValidationError, index int, ioType string, actionSources []DataSource, payloadSources []DataSource, defs []DataSourceDefinition) {
+	actionPath := jsonPathField(jsonPathIndex("$.actions", index), ioType)
+	for _, dsDef := range defs {
+		ds, dsPath, ok := findDataSource(dsDef.Name, actionSources, actionPath)
+		if !ok {
+			ds, dsPath, ok = findDataSource(dsDef.Name, payloadSources, jsonPathField("$", ioType))
+		}
+		if !ok {
+			if !dsDef.Optional {
+				verr.add(actionPath, "missing %s data source %s", strings.TrimSuffix(ioType, "s"), dsDef.Name)
+			}
+			continue
+		}
+		for _, key := range dsDef.Paths {
+			if _, ok := ds.Paths[key]; !ok {
+				verr.add(jsonPathField(jsonPathField(dsPath, "paths"), key), "missing path key for data source %s", dsDef.Name)
+			}
+		}
+		for _, key := range dsDef.DataPaths {
+			if _, ok := ds.DataPaths[key]; !ok {
+				verr.add(jsonPathField(jsonPathField(dsPath, "data_paths"), key), "missing data path key for data source %s", dsDef.Name)
+			}
+		}
+	}
+}
+
+func findDataSource(name string, sources []DataSource, path string) (DataSource, string, bool) {
+	for i, ds := range sources {
+		if ds.Name == name {
+			return ds, jsonPathIndex(path, i), true
+		}
+	}
+	return DataSource{}, "", false
+}
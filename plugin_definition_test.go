@@ -0,0 +1,116 @@
+package cc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPluginDefinition = `{
+	"name": "ras-runner",
+	"actions": [{
+		"name": "compute",
+		"attributes": ["plan"],
+		"inputs": [{"name": "terrain", "paths": ["default"]}, {"name": "calibration", "optional": true}],
+		"outputs": [{"name": "results", "paths": ["hdf"]}]
+	}]
+}`
+
+func TestPluginDefinition(t *testing.T) {
+	definitionPath := filepath.Join(t.TempDir(), "plugin.json")
+	if err := os.WriteFile(definitionPath, []byte(testPluginDefinition), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry := DataStoreTypeRegistryMap{}
+	registry.Register("TEST", struct{}{})
+	stores := []DataStore{{Name: "local", StoreType: "TEST"}}
+	payload := Payload{
+		IOManager: IOManager{
+			Stores: stores,
+			Inputs: []DataSource{{Name: "terrain", StoreName: "local", Paths: map[string]string{"default": "terrain.tif"}}},
+		},
+		Actions: []Action{
+			{Name: "compute", IOManager: IOManager{
+				Attributes: PayloadAttributes{"plan": "04"},
+				Outputs:    []DataSource{{Name: "results", StoreName: "local", Paths: map[string]string{"hdf": "p04.hdf"}}},
+			}},
+		},
+	}
+	for _, definition := range []string{definitionPath, testPluginDefinition} {
+		pm, err := initTestPluginManager(t, WithPayload(payload), WithStoreRegistry(registry), WithEnv(map[string]string{CcPluginDefinition: definition}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pm.PluginDefinition() == nil || pm.PluginDefinition().Name != "ras-runner" {
+			t.Fatalf("expected the plugin definition to be loaded: %+v", pm.PluginDefinition())
+		}
+	}
+
+	invalid := Payload{
+		IOManager: IOManager{Stores: stores},
+		Actions: []Action{
+			{Name: "compute", IOManager: IOManager{
+				Outputs: []DataSource{{Name: "result", StoreName: "local", Paths: map[string]string{"hdf": "p04.hdf"}}},
+				Inputs:  []DataSource{{Name: "terrain", StoreName: "local", Paths: map[string]string{"dem": "terrain.tif"}}},
+			}},
+			{Name: "post-process"},
+		},
+	}
+	def, err := ParsePluginDefinition([]byte(testPluginDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = initTestPluginManager(t, WithPayload(invalid), WithStoreRegistry(registry), WithPluginDefinition(*def))
+	verr := &ValidationError{}
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, found %v", err)
+	}
+	expected := []Violation{
+		{"$.actions[0].attributes.plan", "is required"},
+		{"$.actions[0].inputs[0].paths.default", "missing path key for data source terrain"},
+		{"$.actions[0].outputs", "missing output data source results"},
+		{"$.actions[1].name", "action post-process is not defined by the plugin"},
+	}
+	if !reflect.DeepEqual(verr.Violations, expected) {
+		t.Fatalf("unexpected violations:\n%s", verr)
+	}
+
+	//the definition is checked before any store is connected
+	registry.Register("PROBE", probeStore{})
+	invalid.Stores = []DataStore{{Name: "local", StoreType: "PROBE", Parameters: PayloadAttributes{"fail": "true"}}}
+	_, err = initTestPluginManager(t, WithPayload(invalid), WithStoreRegistry(registry), WithPluginDefinition(*def))
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error before connecting the stores, found %v", err)
+	}
+}
+
+func TestPluginDefinitionMultiEvent(t *testing.T) {
+	var events []string
+	RegisterActionFactory("definition-event-action", func(pm *PluginManager, action Action) (ActionRunner, error) {
+		return &eventTestAction{ActionRunnerBase: ActionRunnerBase{Action: action}, events: &events}, nil
+	})
+	payload := Payload{
+		Actions: []Action{{
+			Name:      "definition-event-action",
+			IOManager: IOManager{Attributes: PayloadAttributes{"event": "{ENV::CC_EVENT_IDENTIFIER}"}},
+		}},
+	}
+	def := PluginDefinition{Name: "events", Actions: []ActionDefinition{{Name: "definition-event-action"}}}
+	pm, err := initTestPluginManager(t, WithPayload(payload), WithPluginDefinition(def), WithEnv(map[string]string{CcEventIdentifier: "1-2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//every event payload is checked, not only the payload of the first event
+	pm.definition = &PluginDefinition{Name: "events", Actions: []ActionDefinition{{Name: "compute"}}}
+	err = pm.RunActions()
+	if err == nil || !strings.Contains(err.Error(), "event 2: payload does not match the plugin definition") {
+		t.Fatalf("expected the second event to be checked against the definition, found %v", err)
+	}
+	if !reflect.DeepEqual(events, []string{"1"}) {
+		t.Fatalf("expected only the first event to run, found %v", events)
+	}
+}
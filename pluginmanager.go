@@ -1,14 +1,24 @@
 package cc
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"maps"
 	"os"
+	"os/signal"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cast"
 )
 
 const (
@@ -32,35 +42,94 @@ const (
 	FsbRootPath         = "FSB_ROOT_PATH"
 	ParmamSubEnv        = "ENV"
 	ParamSubAttr        = "ATTR"
+	ParamSubCc          = "CC"
+
+	//action attribute holding the maximum run time of an action.
+	//accepts a duration string ("90s", "2h") or a number of seconds.
+	//the timeout only interrupts context aware runners (ContextActionRunner or ResultActionRunner).
+	//a plain runner is not stopped: the action waits for Run to return and then fails as interrupted
+	ActionTimeoutAttr = "cc_timeout"
+
+	//action attribute holding a map of environment variables set for commands run with RunCommand
+	ActionEnvAttr = "cc_env"
 )
 
+// ErrActionInterrupted is wrapped by the error returned from RunActionsContext
+// when an action is stopped by a cancelled context, a timeout or a signal
+var ErrActionInterrupted = errors.New("action interrupted")
+
+// ErrInterruptSignal is the cancellation cause used when the process receives SIGINT or SIGTERM
+var ErrInterruptSignal = errors.New("received interrupt signal")
+
 //var substitutionRegexPattern string = `{([^{}]*)}`
 // var substitutionRegex *regexp.Regexp
 
 // Note: group numbers:
 // 1 = TYPE
 // 2 = VARNAME
-// 3 = empty [] (captures the literal "[]")
-// 4 = numeric index
-// 5 = single-quoted key
-// 6 = double-quoted key
+// 3 = path following the name (i.e. ".bold.red1", "[0]", "[]['key']")
+// 4 = modifier operator (":-" default value or ":?" required)
+// 5 = modifier operand (the default value or the required message)
+// 6 = filters (i.e. "|pad:4|upper")
 var substitutionRegex = regexp.MustCompile(
-	`\{(ATTR|VAR|ENV)::([a-zA-Z_][a-zA-Z0-9_]*)(?:` +
-		`(\[\s*\])` + // group 3: captures "[]" when present
-		`|\[\s*([0-9]+)\s*\]` + // group 4: numeric index
-		`|\[\s*'([^']*)'\s*\]` + // group 5: single-quoted key
-		`|\[\s*"([^"]*)"\s*\]` + // group 6: double-quoted key
-		`)?\}`,
+	`\{(ATTR|VAR|ENV|CC)::([a-zA-Z_][a-zA-Z0-9_]*)` +
+		`((?:\.[a-zA-Z0-9_-]+|\[\s*(?:[0-9]*|'[^']*'|"[^"]*")\s*\])*)` + // group 3: path segments
+		`(?:(:-|:\?)([^{}|]*))?` + // groups 4 and 5: {ENV::NAME:-default} or {ENV::NAME:?message}
+		`((?:\|[a-zA-Z]+(?::[^{}|]*)?)*)` + // group 6: {CC::EVENT_NUMBER|pad:4}
+		`\}`,
+)
+
+// Note: group numbers:
+// 1 = dotted key
+// 2 = numeric index
+// 3 = single-quoted key
+// 4 = double-quoted key
+// none = empty [] (inflation)
+var pathSegmentRegex = regexp.MustCompile(
+	`\.([a-zA-Z0-9_-]+)` +
+		`|\[\s*(?:([0-9]+)|'([^']*)'|"([^"]*)")?\s*\]`,
+)
+
+const (
+	substitutionDefaultOp  = ":-"
+	substitutionRequiredOp = ":?"
 )
 
+// ErrRequiredSubstitution is wrapped by the error for a required template reference
+// ({ENV::NAME:?message}) that can not be resolved
+var ErrRequiredSubstitution = errors.New("required substitution")
+
+// SubstitutionError locates a payload template that could not be substituted
+type SubstitutionError struct {
+	Location string //JSON path of the template, for example $.actions[0].inputs[1].paths.default
+	Template string
+	Err      error
+}
+
+func (se *SubstitutionError) Error() string {
+	return fmt.Sprintf("substitution failed at %s in %q: %s", se.Location, se.Template, se.Err)
+}
+
+func (se *SubstitutionError) Unwrap() error {
+	return se.Err
+}
+
 // use a map to declare the set of substitutions variables that will be substituted automatically
 // when the pluginmanager is initialized
 var validAutoSubstitution map[string]struct{} = map[string]struct{}{
 	"ATTR": {},
 	"ENV":  {},
+	"CC":   {},
 }
 
-var maxretry int = 100
+// variables of the CC:: substitution namespace.  they are filled from the PluginManager state
+const (
+	CcVarEventId     = "EVENT_ID"
+	CcVarEventNumber = "EVENT_NUMBER"
+	CcVarManifestId  = "MANIFEST_ID"
+	CcVarPayloadId   = "PAYLOAD_ID"
+	CcVarAction      = "ACTION" //only available within an action
+)
 
 type NamedAction interface {
 	GetName() string
@@ -71,6 +140,8 @@ type ActionRunnerBase struct {
 	ContinueOnError bool
 	PluginManager   *PluginManager
 	Action          Action
+	ctx             context.Context
+	workspace       *actionWorkspace
 }
 
 func (arb ActionRunnerBase) GetName() string {
@@ -89,10 +160,36 @@ func (arb *ActionRunnerBase) Log(msg string, args ...any) {
 	arb.PluginManager.Logger.Action(msg, args...)
 }
 
+// Context returns the context of the running action.
+// The context is cancelled when the action times out or the plugin receives SIGINT/SIGTERM.
+// Long running Run implementations should watch ctx.Done() and return promptly.
+func (arb *ActionRunnerBase) Context() context.Context {
+	if arb.ctx == nil {
+		return context.Background()
+	}
+	return arb.ctx
+}
+
+func (arb *ActionRunnerBase) setContext(ctx context.Context) {
+	arb.ctx = ctx
+}
+
 type ActionRunner interface {
 	Run() error
 }
 
+// ContextActionRunner is a context aware ActionRunner.
+// When a registered runner implements ContextActionRunner, RunContext is called in place of Run.
+// RunContext must return once ctx is done.  Action timeouts and cancellation can not interrupt a plain Run:
+// the PluginManager waits for a plain Run to return, including after SIGINT or SIGTERM.
+type ContextActionRunner interface {
+	RunContext(ctx context.Context) error
+}
+
+type contextSetter interface {
+	setContext(ctx context.Context)
+}
+
 var ActionRegistry ActionRunnerRegistry = make(map[string]ActionRunner)
 
 func (arr *ActionRunnerRegistry) RegisterAction(actionName string, runner ActionRunner) {
@@ -110,175 +207,632 @@ type ActionRunnerRegistry map[string]ActionRunner
 // }
 
 // PluginManager is a Manager designed to simplify access to stores and usage of plugin api calls
+//
+// Concurrency: once InitPluginManager returns, the store and data source lookups and the data
+// transfer methods of PluginManager, Action and IOManager (GetStore, GetDataSource, GetDataSourceRef,
+// GetReader, Get, Put, Copy, CopyFileToLocal and CopyFileToRemote) and the result accessors
+// (RunSummary, EventResults) are safe for concurrent use, including from goroutines started by actions.
+// Store sessions are read and connected under a lock of the manager.  The payload attributes, stores and data
+// sources must be treated as read only while actions run, and RunActions must not be called
+// concurrently on the same manager.
 type PluginManager struct {
-	EventIdentifier string
-	ccStore         CcStore
-	Logger          *CcLogger
+	EventIdentifier   string
+	ccStore           CcStore
+	Logger            *CcLogger
+	config            PluginManagerConfig
+	manifestId        string
+	payloadId         string
+	summary           RunSummary
+	lookupEnv         EnvLookup
+	storeRegistry     DataStoreTypeRegistryMap
+	definition        *PluginDefinition
+	mu                sync.RWMutex
+	sessionMu         sync.RWMutex //guards the sessions of the stores connected by the manager
+	sessions          map[string]any
+	writes            writeTracker
+	returnOnInterrupt bool
+	events            EventSequence
+	eventResults      []EventResult
+	rawPayload        Payload
 	Payload
 }
 
 type PluginManagerConfig struct {
+	//default number of times a failed action is retried.  actions can override this with a payload retry policy
 	MaxRetry int
+
+	//default backoff before the first retry and the upper limit of the exponential backoff
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+
+	//maximum number of independent actions run at the same time. values less than 1 run one action at a time
+	MaxParallelActions int
+
+	//report the execution plan (see PluginManager.Plan) instead of running the actions.
+	//this can also be enabled by setting CC_DRY_RUN=true.  The plan is logged and, when
+	//DryRunOutput is set, also written to it as text
+	DryRun       bool
+	DryRunOutput io.Writer
+
+	//when true (or CC_ENABLE_CHECKPOINTS=true) completed actions are recorded in a checkpoint in the CcStore
+	//and skipped when the event is rerun with unchanged inputs.  Input data changes are detected by size and
+	//modification time for local (FSB) inputs and stores whose session implements StoreObjectInfo.  Actions
+	//with any other input always run.
+	//ForceRerun (or CC_FORCE_RERUN=true) runs every action regardless of the checkpoint
+	EnableCheckpoints bool
+	ForceRerun        bool
+
+	//when false (the default) RunActions fails before running anything if a payload action has no registered runner.
+	//when true the unregistered actions are skipped with a warning
+	AllowUnregisteredActions bool
+
+	//scratch workspace options.  see ActionRunnerBase.Workspace
+	Workspace WorkspaceConfig
+
+	//time in-flight store writes are given to finish after SIGINT or SIGTERM. zero uses DefaultShutdownGracePeriod
+	ShutdownGracePeriod time.Duration
+
+	//resource usage of each action is sampled and logged on this interval and the peak usage is added
+	//to the action result. zero uses DefaultResourceMonitorInterval
+	ResourceMonitorInterval time.Duration
+	DisableResourceMonitor  bool
+
+	//handling of payload templates that can not be resolved during initialization.
+	//empty uses CC_SUBSTITUTION_MODE or SubstitutionLenient when that is not set
+	SubstitutionMode SubstitutionMode
 }
 
+// InitPluginManagerWithConfig initializes a plugin manager with a configuration.
+// It is equivalent to InitPluginManager(WithConfig(config)).
 func InitPluginManagerWithConfig(config PluginManagerConfig) (*PluginManager, error) {
-	maxretry = config.MaxRetry
-	return InitPluginManager()
+	return InitPluginManager(WithConfig(config))
 }
 
-func connectStores(stores *[]DataStore) error {
-	for i, ds := range *stores {
-		newInstance, err := DataStoreTypeRegistry.New(ds.StoreType)
+// connectStores makes a connection to each store.
+// Connections are cached by store configuration and reused when a store is reconnected with the same configuration.
+func (pm *PluginManager) connectStores(stores *[]DataStore) error {
+	for i := range *stores {
+		store := &(*stores)[i]
+		if store.sessionMu == nil {
+			store.sessionMu = &pm.sessionMu
+		}
+		ds := store.config()
+		newInstance, err := pm.storeTypeRegistry().New(ds.StoreType)
 		if err != nil {
 			return err
 		}
 		if cds, ok := newInstance.(ConnectionDataStore); ok {
-			conn, err := cds.Connect(ds)
+			key, err := json.Marshal(ds)
 			if err != nil {
 				return err
 			}
-			(*stores)[i].Session = conn
+			conn, err := pm.storeSession(string(key), cds, ds)
+			if err != nil {
+				return err
+			}
+			store.setSession(conn)
 		}
 	}
 	return nil
 }
 
-func InitPluginManager() (*PluginManager, error) {
-	manifestId := os.Getenv(CcManifestId)
-	payloadId := os.Getenv(CcPayloadId)
-	registerStoreTypes()
-	//substitutionRegex, _ = regexp.Compile(substitutionRegexPattern)
+// InitPluginManager creates a PluginManager for the current payload.
+//
+// Without options the manifest, payload and event identifiers are read from the environment,
+// the CcStore is created with NewCcStore and the payload is retrieved from the store.
+// Options can supply any of these instead, which allows several managers to run in one
+// process and fakes to be injected in tests.
+//
+// When the plugin has a PluginDefinition the payload is checked against it before any store is
+// connected and an error lists every missing action, attribute, data source and path key.
+// In a multi-event run the payload of each event is checked when the event is loaded.
+//
+// When the event identifier is a list or range of events (for example CC_EVENT_IDENTIFIER=1-500)
+// or WithEventIdentifiers is used, RunActions runs the actions once per event.  The payload is
+// re-substituted for each event and stores with unchanged configuration keep their connection.
+func InitPluginManager(opts ...Option) (*PluginManager, error) {
+	options := pluginManagerOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	var manager PluginManager
-	manager.EventIdentifier = os.Getenv(CcEventIdentifier)
-	manager.Logger = NewCcLogger(CcLoggerInput{manifestId, payloadId, nil})
+	manager.config = options.config
+	manager.lookupEnv = options.lookupEnv
+	manager.storeRegistry = options.storeRegistry
+	manager.returnOnInterrupt = options.returnOnInterrupt
+	if manager.storeRegistry == nil {
+		registerStoreTypes()
+	}
+
+	manifestId := manager.getenv(CcManifestId)
+	payloadId := manager.getenv(CcPayloadId)
+	//substitutionRegex, _ = regexp.Compile(substitutionRegexPattern)
+	manager.manifestId = manifestId
+	manager.payloadId = payloadId
+	manager.EventIdentifier = manager.getenv(CcEventIdentifier)
+	if options.eventIdentifier != nil {
+		manager.EventIdentifier = *options.eventIdentifier
+	}
+	if options.eventIdentifiers != nil {
+		manager.events = newEventSequence(options.eventIdentifiers...)
+	} else if events, ok := ParseEventIdentifiers(manager.EventIdentifier); ok {
+		manager.events = events
+	}
+	if manager.events.Len() > 0 {
+		manager.EventIdentifier = manager.events.First()
+	}
+	manager.Logger = options.logger
+	if manager.Logger == nil {
+		manager.Logger = NewCcLogger(CcLoggerInput{manifestId, payloadId, nil})
+	}
 
 	// Create the store based on configuration
-	store, err := NewCcStore()
+	store := options.store
+	if store == nil {
+		var err error
+		store, err = newCcStore(manager.envLookup(), manifestId, payloadId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create store: %w", err)
+		}
+	}
+	manager.ccStore = store
+
+	var payload Payload
+	if options.payload != nil {
+		//substitution modifies the payload in place, so work on a copy of the caller's payload
+		var err error
+		payload, err = clonePayload(*options.payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy payload: %w", err)
+		}
+	} else {
+		var err error
+		payload, err = store.GetPayload()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get payload: %w", err)
+		}
+	}
+
+	if manager.multiEvent() {
+		//keep an unsubstituted copy of the payload to resolve for each event
+		var err error
+		manager.rawPayload, err = clonePayload(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy payload: %w", err)
+		}
+	}
+
+	//the payload of every event is checked against the plugin definition when it is loaded
+	manager.definition = options.pluginDefinition
+	if manager.definition == nil {
+		var err error
+		manager.definition, err = manager.loadPluginDefinition()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := manager.loadPayload(payload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create store: %w", err)
+		return nil, err
+	}
+	return &manager, nil
+}
+
+// connect makes a new connection to a store using the manager env lookup when the store supports it
+func (pm *PluginManager) connect(cds ConnectionDataStore, ds DataStore) (any, error) {
+	if ec, ok := cds.(envConnector); ok {
+		return ec.connectWithEnv(ds, pm.envLookup())
 	}
+	return cds.Connect(ds)
+}
 
-	manager.ccStore = store
-	payload, err := store.GetPayload()
+// storeSession returns the cached connection for a store configuration or connects to the store.
+// The connection is made without holding the manager lock so a slow store does not block the
+// other manager calls.  When two connections to the same store race, the first one cached is kept.
+func (pm *PluginManager) storeSession(key string, cds ConnectionDataStore, ds DataStore) (any, error) {
+	pm.mu.RLock()
+	conn, ok := pm.sessions[key]
+	pm.mu.RUnlock()
+	if ok {
+		return conn, nil
+	}
+
+	conn, err := pm.connect(cds, ds)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get payload: %w", err)
+		return nil, err
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if cached, ok := pm.sessions[key]; ok {
+		if closer, ok := conn.(io.Closer); ok {
+			closer.Close()
+		}
+		return cached, nil
 	}
+	if pm.sessions == nil {
+		pm.sessions = make(map[string]any)
+	}
+	pm.sessions[key] = conn
+	return conn, nil
+}
 
-	manager.IOManager = payload.IOManager //@TODO do I absolutely need these two lines?
-	manager.Actions = payload.Actions
+// loadPayload sets the manager payload, performs variable substitution and connects the stores
+func (pm *PluginManager) loadPayload(payload Payload) error {
+	pm.IOManager = payload.IOManager //@TODO do I absolutely need these two lines?
+	pm.Actions = payload.Actions
 
 	//perform payload variable substitution
-	err = manager.substituteVariables()
+	err := pm.substituteVariables()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	//check the structure of the substituted payload before connecting to any store
+	err = pm.Payload.validate(pm.storeTypeRegistry())
+	if err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	//check the payload against the plugin definition before connecting to any store
+	if pm.definition != nil {
+		if err := pm.definition.CheckPayload(&pm.Payload); err != nil {
+			return fmt.Errorf("payload does not match the plugin definition: %w", err)
+		}
 	}
 
 	//make connections to the plugin manager stores
-	err = connectStores(&manager.Stores)
+	err = pm.connectStores(&pm.Stores)
 	if err != nil {
-		return nil, err
+		if !pm.dryRunEnabled() {
+			return err
+		}
+		pm.Logger.Warn("store connection failed: reported in the execution plan", "error", err.Error())
 	}
 
-	for i := range manager.Actions {
+	for i := range pm.Actions {
 		//add the pm manager IOManager as a parent to the action IOManager
 		//so that the action IOManager can recursively search through parent
 		//IOManager elements
-		manager.Actions[i].IOManager.SetParent(&manager.IOManager)
+		pm.Actions[i].IOManager.SetParent(&pm.IOManager)
 
 		//make connection to the action stores
-		err = connectStores(&manager.Actions[i].Stores)
+		err = pm.connectStores(&pm.Actions[i].Stores)
 		if err != nil {
-			return nil, err
+			if !pm.dryRunEnabled() {
+				return err
+			}
+			pm.Logger.Warn("store connection failed: reported in the execution plan", "action", pm.Actions[i].Name, "error", err.Error())
 		}
 	}
-
-	return &manager, err
+	return nil
 }
 
 // RunActions iterates through the registered actions and executes them.
+// It is equivalent to calling RunActionsContext with a background context.
+func (pm *PluginManager) RunActions() error {
+	return pm.RunActionsContext(context.Background())
+}
+
+// RunActionsContext iterates through the registered actions and executes them.
 //
 // It iterates over the `Actions` slice in the `PluginManager`, and for each action,
-// it searches the `ActionRegistry` for a matching runner implementation. If a match is found
-// (identified by the action name), the runner is instantiated using reflection,
-// its `PluginManager`, `Action`, and `ActionName` fields are set, and its `Run` method is called.
+// it creates a runner from the factory registered with RegisterActionFactory (or RegisterActionType).
+// If no factory is registered the runner is instantiated from the `ActionRegistry` prototype using
+// reflection, its `PluginManager`, `Action`, and `ActionName` fields are set, and its `Run` method is called.
+// Runner construction errors are returned rather than panicking.  Payload actions without a registered
+// runner fail the run before any action starts unless PluginManagerConfig.AllowUnregisteredActions is set.
 //
-// This method relies heavily on reflection to dynamically instantiate and invoke the action runners,
-// making it flexible but also potentially less performant than statically typed calls.
-// It assumes that all action runner structs have fields named "PluginManager", "Action", and "ActionName".
+// Runners may implement the optional ActionValidator, ActionSetup and ActionTeardown interfaces.
+// Every runner is validated before any action runs.  Each action then runs Setup, Run and Teardown,
+// with Teardown called even when Setup or Run fail.  Errors are reported per phase as ActionError values.
+//
+// Actions may declare the names of the actions they depend on in "depends_on".  The actions are
+// run as a dependency graph with up to PluginManagerConfig.MaxParallelActions independent actions
+// running at once.  A failed action causes its dependents to be skipped.  Dependency cycles and
+// unknown dependencies are reported before any action runs.  Payloads without dependencies run
+// their actions one after another in payload order.
+//
+// Failed actions are retried according to the action "retry" policy, falling back to the
+// PluginManagerConfig retry settings.
+//
+// A structured ActionResult is recorded for every action and the resulting RunSummary is written
+// to the CcStore under the manifest.  Runners implementing ResultActionRunner contribute their
+// outputs and results to the summary.
+//
+// When checkpoints are enabled, completed actions are checkpointed in the CcStore keyed by manifest, payload
+// and event.  When the event is rerun, actions whose resolved inputs are unchanged are skipped unless a rerun
+// is forced.
+//
+// In dry run mode (PluginManagerConfig.DryRun or CC_DRY_RUN=true) the execution plan of every event
+// is reported and no action is run.
+//
+// A multi-event manager runs the actions once per event.  Each event is reported in EventResults
+// and a failed event does not stop the remaining events.
+//
+// Each action runs with a context derived from ctx.  The context is cancelled when ctx is cancelled,
+// when the action exceeds the duration in its "cc_timeout" attribute, or when the process receives
+// SIGINT or SIGTERM.  The returned error names the interrupted action and wraps ErrActionInterrupted.
+//
+// After SIGINT or SIGTERM no new action or store write is started.  Store writes already in flight
+// are given PluginManagerConfig.ShutdownGracePeriod from the signal to finish, the run summary is
+// written with the INTERRUPTED status and the process exits with ExitCodeInterrupted.  A manager
+// created with WithReturnOnInterrupt returns an error wrapping ErrInterruptSignal instead of exiting.
 //
 // @TODO review error handling here.....
-func (pm *PluginManager) RunActions() error {
-	for _, action := range pm.Actions {
-		for runnerName, runner := range ActionRegistry {
-			if action.Name == runnerName {
-				pm.Logger.Info("Running " + action.Name)
-				t := reflect.TypeOf(runner).Elem() //runner is a pointer, so take the value of it
-				pointerVal := reflect.New(t)       //create a new struct instance from type t
-				structType := pointerVal.Elem()
-				structType.FieldByName("PluginManager").Set(reflect.ValueOf(pm))
-				structType.FieldByName("Action").Set(reflect.ValueOf(action))
-				structType.FieldByName("ActionName").Set(reflect.ValueOf(runnerName))
-				runMethod := pointerVal.MethodByName("Run") //must call method on the pointer receiver
-				if runMethod.IsValid() {
-					results := runMethod.Call(nil)
-					//only a single error should be returned as results
-					if len(results) > 0 {
-						if err, ok := results[0].Interface().(error); ok {
-							if !(structType.FieldByName("ContinueOnError").Bool()) {
-								return fmt.Errorf("error running %s: %s", runnerName, err)
-							}
-						}
-					}
-				}
-				pm.Logger.Info("Completed " + action.Name)
+func (pm *PluginManager) RunActionsContext(ctx context.Context) error {
+	if pm.dryRunEnabled() {
+		return pm.dryRun()
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case sig := <-sigs:
+			pm.stopStoreWrites()
+			cancel(fmt.Errorf("%w: %s", ErrInterruptSignal, sig))
+		case <-ctx.Done():
+		}
+	}()
+
+	var err error
+	if pm.multiEvent() {
+		err = pm.runEvents(ctx)
+	} else {
+		err = pm.runEventActions(ctx)
+	}
+	if interrupted(ctx) {
+		pm.exitInterrupted()
+	}
+	return err
+}
+
+// runEventActions runs the payload actions for the current event
+func (pm *PluginManager) runEventActions(ctx context.Context) error {
+	graph, err := newActionGraph(pm.Actions)
+	if err != nil {
+		return err
+	}
+
+	pm.trackWrites()
+	runners, err := pm.newActionRunners(pm.Actions)
+	if err != nil {
+		return err
+	}
+
+	if !pm.config.AllowUnregisteredActions {
+		err = checkUnregisteredActions(pm.Actions, runners)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateActionRunners(pm.Actions, runners)
+	if err != nil {
+		return err
+	}
+
+	summary := RunSummary{
+		Manifest:        pm.manifestId,
+		Payload:         pm.payloadId,
+		EventIdentifier: pm.EventIdentifier,
+		Status:          COMPUTING,
+		Start:           time.Now(),
+	}
+	results := make([]ActionResult, len(pm.Actions))
+	checkpoints := pm.newCheckpointer()
+	force := pm.forceRerun()
+
+	states, err := graph.run(ctx, pm.config.MaxParallelActions, pm.Logger, func(ctx context.Context, i int, action Action) error {
+		runner := runners[i]
+		if runner == nil {
+			pm.Logger.Warn("skipping unregistered action", "action", action.Name)
+			results[i] = ActionResult{Action: action.Name, Status: ActionSkipped, Error: "unregistered action"}
+			return nil
+		}
+		var fingerprint string
+		if checkpoints != nil {
+			fingerprint, err = pm.actionFingerprint(action)
+			if err != nil {
+				return err
 			}
-			//}
 		}
+		if !force && checkpoints.completed(i, action, fingerprint) {
+			pm.Logger.Info("Skipping " + action.Name + ": completed in a previous run")
+			results[i] = ActionResult{Action: action.Name, Status: ActionSkipped, Checkpointed: true}
+			return nil
+		}
+		pm.Logger.Info("Running " + action.Name)
+		start := time.Now()
+		result, err := pm.executeAction(ctx, action, runner)
+		completeResult(&result, action, start, err)
+		results[i] = result
+		if err == nil {
+			if cperr := checkpoints.record(i, action, fingerprint); cperr != nil {
+				pm.Logger.Warn("failed to write checkpoint", "action", action.Name, "error", cperr.Error())
+			}
+		} else {
+			if errors.Is(err, ErrActionInterrupted) {
+				return err
+			}
+			if cr, ok := runner.(continueOnErrorRunner); !ok || !cr.continueOnError() {
+				return err
+			}
+			pm.Logger.Warn("continuing after action error", "action", action.Name, "error", err.Error())
+		}
+		pm.Logger.Info("Completed " + action.Name)
+		return nil
+	})
+
+	if interrupted(ctx) {
+		pm.drainStoreWrites()
 	}
-	return nil
+	pm.removeEventWorkspaceRoot()
+
+	skippedResults(results, pm.Actions, states, err)
+	summary.End = time.Now()
+	summary.Actions = results
+	summary.Status = SUCCEEDED
+	if err != nil {
+		summary.Status = FAILED
+		if interrupted(ctx) {
+			summary.Status = INTERRUPTED
+		}
+		summary.Error = err.Error()
+	}
+	pm.mu.Lock()
+	pm.summary = summary
+	pm.mu.Unlock()
+	pm.writeRunSummary(summary)
+	return err
+}
+
+// actionContext applies the optional timeout of an action to ctx
+func actionContext(ctx context.Context, action Action) (context.Context, context.CancelFunc, error) {
+	timeout, err := actionTimeout(action)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	if timeout <= 0 {
+		return ctx, func() {}, nil
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("action %s exceeded its timeout of %s: %w", action.Name, timeout, context.DeadlineExceeded))
+	return ctx, cancel, nil
+}
+
+// contextAware reports whether a runner receives the action context and can be interrupted by it
+func contextAware(runner ActionRunner) bool {
+	switch runner.(type) {
+	case ResultActionRunner, ContextActionRunner:
+		return true
+	}
+	return false
+}
+
+// runAction runs a single attempt of an action with the action timeout applied to ctx.
+//
+// Timeouts and cancellation only interrupt context aware runners (ContextActionRunner or ResultActionRunner),
+// which must return once their context is done.  A plain Run can not be interrupted.  runAction always
+// waits for the runner to return, so a runner is never left running, and an attempt whose context was
+// done before a plain Run returned fails with ErrActionInterrupted.
+func (pm *PluginManager) runAction(ctx context.Context, action Action, runner ActionRunner) (result ActionResult, err error) {
+	ctx, cancel, err := actionContext(ctx, action)
+	defer cancel()
+	if err != nil {
+		return ActionResult{}, err
+	}
+
+	if cs, ok := runner.(contextSetter); ok {
+		cs.setContext(ctx)
+	}
+
+	type runOutput struct {
+		result ActionResult
+		err    error
+	}
+	done := make(chan runOutput, 1)
+	go func() {
+		switch r := runner.(type) {
+		case ResultActionRunner:
+			result, err := r.RunResult(ctx)
+			done <- runOutput{result, err}
+		case ContextActionRunner:
+			done <- runOutput{err: r.RunContext(ctx)}
+		default:
+			done <- runOutput{err: runner.Run()}
+		}
+	}()
+
+	var out runOutput
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		if !contextAware(runner) {
+			pm.Logger.Warn("action runner does not accept a context: waiting for Run to return", "action", action.Name)
+		}
+		out = <-done
+	}
+	result, err = out.result, out.err
+	interrupted := ctx.Err() != nil && (err != nil || !contextAware(runner))
+	if interrupted {
+		if err == nil {
+			err = fmt.Errorf("%w: %s: %w", ErrActionInterrupted, action.Name, context.Cause(ctx))
+		} else {
+			//the runner returned because its context was cancelled
+			err = fmt.Errorf("%w: %s: %w: %w", ErrActionInterrupted, action.Name, context.Cause(ctx), err)
+		}
+		pm.Logger.Error("action interrupted", "action", action.Name, "cause", context.Cause(ctx).Error())
+	}
+	return result, err
+}
+
+// actionTimeout reads the optional cc_timeout attribute of an action.
+// A zero duration means the action has no timeout.
+func actionTimeout(action Action) (time.Duration, error) {
+	val, ok := action.Attributes[ActionTimeoutAttr]
+	if !ok {
+		return 0, nil
+	}
+	if s, ok := val.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, nil
+		}
+	}
+	seconds, err := cast.ToFloat64E(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s attribute for action %s: %v", ActionTimeoutAttr, action.Name, val)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
 }
 
 // -----------------------------------------------
 // Wrapped IOManager functions
 // -----------------------------------------------
 
-func (pm PluginManager) GetStore(name string) (*DataStore, error) {
+func (pm *PluginManager) GetStore(name string) (*DataStore, error) {
 	return pm.IOManager.GetStore(name)
 }
 
-func (pm PluginManager) GetDataSource(input GetDsInput) (DataSource, error) {
+func (pm *PluginManager) GetDataSource(input GetDsInput) (DataSource, error) {
 	return pm.IOManager.GetDataSource(input)
 }
 
-func (pm PluginManager) GetInputDataSource(name string) (DataSource, error) {
+func (pm *PluginManager) GetInputDataSource(name string) (DataSource, error) {
 	return pm.IOManager.GetInputDataSource(name)
 }
 
-func (pm PluginManager) GetOutputDataSource(name string) (DataSource, error) {
+func (pm *PluginManager) GetOutputDataSource(name string) (DataSource, error) {
 	return pm.IOManager.GetOutputDataSource(name)
 }
 
-func (pm PluginManager) GetReader(input DataSourceOpInput) (io.ReadCloser, error) {
+func (pm *PluginManager) GetReader(input DataSourceOpInput) (io.ReadCloser, error) {
 	return pm.IOManager.GetReader(input)
 }
 
-func (pm PluginManager) Get(input DataSourceOpInput) ([]byte, error) {
+func (pm *PluginManager) Get(input DataSourceOpInput) ([]byte, error) {
 	return pm.IOManager.Get(input)
 }
 
-func (pm PluginManager) Put(input PutOpInput) (int, error) {
+func (pm *PluginManager) Put(input PutOpInput) (int, error) {
 	return pm.IOManager.Put(input)
 }
 
-func (pm PluginManager) Copy(src DataSourceOpInput, dest DataSourceOpInput) error {
+func (pm *PluginManager) Copy(src DataSourceOpInput, dest DataSourceOpInput) error {
 	return pm.IOManager.Copy(src, dest)
 }
 
-func (pm PluginManager) CopyFileToLocal(input CopyToLocalInput) error {
+func (pm *PluginManager) CopyFileToLocal(input CopyToLocalInput) error {
 	return pm.IOManager.CopyFileToLocal(input)
 }
 
-func (pm PluginManager) CopyFileToRemote(input CopyFileToRemoteInput) error {
+func (pm *PluginManager) CopyFileToRemote(input CopyFileToRemoteInput) error {
 	return pm.IOManager.CopyFileToRemote(input)
 }
 
@@ -286,37 +840,44 @@ func (pm PluginManager) CopyFileToRemote(input CopyFileToRemoteInput) error {
 // Private utility functions
 // -----------------------------------------------
 func (pm *PluginManager) substituteVariables() error {
+	mode, err := pm.substitutionMode()
+	if err != nil {
+		return err
+	}
+	c := &substitutionCollector{mode: mode}
+	payloadVars := pm.ccVariables("")
 
 	//allow env substitution within payload attributes
-	pm.substituteMapVariables(pm.Attributes, false)
+	pm.substituteMap(pm.Attributes, false, payloadVars, "$.attributes", c)
 
 	//allow substitution on data store params
-	for _, store := range pm.Stores {
-		pm.substituteMapVariables(store.Parameters, true)
+	for i, store := range pm.Stores {
+		pm.substituteMap(store.Parameters, true, payloadVars, jsonPathField(jsonPathIndex("$.stores", i), "params"), c)
 	}
 
 	//allow substitution on input data source paths and data paths
 	for i, ds := range pm.Inputs {
-		err := pathsSubstitute(&ds, pm.Attributes)
-		if err != nil {
-			return err
-		}
+		pathsSubstitute(&ds, pm.Attributes, pm.envLookup(), payloadVars, jsonPathIndex("$.inputs", i), c)
 		pm.Inputs[i] = ds
 	}
 
 	//allow substitution on input data source paths and data paths
 	for i, ds := range pm.Outputs {
-		err := pathsSubstitute(&ds, pm.Attributes)
-		if err != nil {
-			return err
-		}
+		pathsSubstitute(&ds, pm.Attributes, pm.envLookup(), payloadVars, jsonPathIndex("$.outputs", i), c)
 		pm.Outputs[i] = ds
 	}
 
-	for _, action := range pm.Actions {
+	for a, action := range pm.Actions {
+		actionVars := pm.ccVariables(action.Name)
+		actionPath := jsonPathIndex("$.actions", a)
 
 		//allow env and payload attribute substition within action attributes
-		pm.substituteMapVariables(action.Attributes, true)
+		pm.substituteMap(action.Attributes, true, actionVars, jsonPathField(actionPath, "attributes"), c)
+
+		//allow substitution on action data store params
+		for i, store := range action.Stores {
+			pm.substituteMap(store.Parameters, true, actionVars, jsonPathField(jsonPathIndex(jsonPathField(actionPath, "stores"), i), "params"), c)
+		}
 
 		//create a map for a combined action parameter and payload parameter list
 		combinedParams := maps.Clone(pm.Attributes)
@@ -326,120 +887,167 @@ func (pm *PluginManager) substituteVariables() error {
 		maps.Copy(combinedParams, action.Attributes)
 
 		for i, ds := range action.Inputs {
-			err := pathsSubstitute(&ds, combinedParams)
-			if err != nil {
-				return err
-			}
+			pathsSubstitute(&ds, combinedParams, pm.envLookup(), actionVars, jsonPathIndex(jsonPathField(actionPath, "inputs"), i), c)
 			action.Inputs[i] = ds
 		}
 
 		for i, ds := range action.Outputs {
-			err := pathsSubstitute(&ds, combinedParams)
-			if err != nil {
-				return err
-			}
+			pathsSubstitute(&ds, combinedParams, pm.envLookup(), actionVars, jsonPathIndex(jsonPathField(actionPath, "outputs"), i), c)
 			action.Outputs[i] = ds
 		}
 	}
 
-	return nil
+	for _, w := range c.warnings {
+		pm.Logger.Warn("unresolved substitution", "location", w.Location, "template", w.Template, "error", w.Err.Error())
+	}
+	return c.err()
+}
+
+// ccVariables returns the values of the CC:: substitution namespace.
+// The ACTION variable is only set when action is not empty.
+func (pm *PluginManager) ccVariables(action string) map[string]string {
+	eventNumber := pm.getenv(CcEventNumber)
+	if _, err := strconv.Atoi(pm.EventIdentifier); eventNumber == "" && err == nil {
+		eventNumber = pm.EventIdentifier
+	}
+	vars := map[string]string{
+		CcVarEventId:     pm.EventIdentifier,
+		CcVarEventNumber: eventNumber,
+		CcVarManifestId:  pm.manifestId,
+		CcVarPayloadId:   pm.payloadId,
+	}
+	if action != "" {
+		vars[CcVarAction] = action
+	}
+	return vars
 }
 
 // ----------------------------------------
 // substitutes map (i.e. payload or action attributes)
 // takes the set of attributes as a param argument to support recursing into attribute maps and arrays
-func (pm *PluginManager) substituteMapVariables(params map[string]any, attrSub bool) {
-	for param, valAny := range params {
-		switch val := valAny.(type) {
+func (pm *PluginManager) substituteMapVariables(params map[string]any, attrSub bool) error {
+	mode, _ := pm.substitutionMode()
+	c := &substitutionCollector{mode: mode}
+	pm.substituteMap(params, attrSub, pm.ccVariables(""), "$", c)
+	return c.err()
+}
+
+// substituteMap substitutes the templates in params.  location is the JSON path of params in the payload.
+// Templates that can not be resolved are left unchanged and recorded in the collector.
+func (pm *PluginManager) substituteMap(params map[string]any, attrSub bool, ccVars map[string]string, location string, c *substitutionCollector) {
+	for _, param := range sortedKeys(params) {
+		paramLocation := jsonPathField(location, param)
+		switch val := params[param].(type) {
 		case string:
-			newvals, err := parameterSubstitute(paramSubInput{
+			input := paramSubInput{
 				TemplateKey:                param,
 				Template:                   val,
 				Attributes:                 pm.Attributes,
 				AllowAttributeSubstitution: attrSub,
-			})
-			if err == nil {
-				delete(params, param)
-				for k, v := range newvals {
-					params[k] = v
-				}
+				LookupEnv:                  pm.envLookup(),
+				CcVars:                     ccVars,
+			}
+			newvals, err := parameterSubstitute(input)
+			if err != nil {
+				c.unresolved(paramLocation, val, err, false)
+				continue
+			}
+			c.check(paramLocation, input)
+			delete(params, param)
+			for k, v := range newvals {
+				params[k] = v
 			}
 		case map[string]any:
-			pm.substituteMapVariables(val, attrSub)
+			pm.substituteMap(val, attrSub, ccVars, paramLocation, c)
 		case []string:
-			newslice := handleSliceSub(val, pm, attrSub)
-			params[param] = newslice
+			newslice := handleSliceSub(val, pm, attrSub, ccVars, paramLocation, c)
+			strslice := make([]string, len(newslice))
+			for i, v := range newslice {
+				strslice[i] = v.(string)
+			}
+			params[param] = strslice
 		case []any:
-			newslice := handleSliceSub(val, pm, attrSub)
-			params[param] = newslice
+			params[param] = handleSliceSub(val, pm, attrSub, ccVars, paramLocation, c)
 		}
 	}
 }
 
-func pathsSubstitute(ds *DataSource, attr map[string]any) error {
+// pathsSubstitute substitutes the name, paths and data paths of a data source.
+// location is the JSON path of the data source in the payload.
+// Data source templates that can not be resolved always fail initialization.
+func pathsSubstitute(ds *DataSource, attr map[string]any, lookupEnv EnvLookup, ccVars map[string]string, location string, c *substitutionCollector) {
 	//handle data source name substitution
-	nameResult, err := parameterSubstitute(paramSubInput{
+	nameLocation := jsonPathField(location, "name")
+	nameInput := paramSubInput{
 		TemplateKey:                "name", //this is the data source name, so we will not allow inflating into multiple paths.  key doesn't matter here
 		Template:                   ds.Name,
 		Attributes:                 attr,
 		AllowAttributeSubstitution: true,
-	})
-	if err != nil {
-		return err
+		LookupEnv:                  lookupEnv,
+		CcVars:                     ccVars,
 	}
-	if nameout, ok := nameResult["name"]; ok {
+	nameResult, err := parameterSubstitute(nameInput)
+	if err != nil {
+		c.unresolved(nameLocation, ds.Name, err, true)
+	} else if nameout, ok := nameResult["name"]; ok {
+		c.check(nameLocation, nameInput)
 		ds.Name = nameout
 	} else {
-		return fmt.Errorf("invalid data source name substitution for %s", ds.Name)
+		c.unresolved(nameLocation, ds.Name, fmt.Errorf("invalid data source name substitution for %s", ds.Name), true)
 	}
 
-	//handle data source paths substitution
-	for k, p := range ds.Paths {
-		paths, err := parameterSubstitute(paramSubInput{
-			TemplateKey:                k,
-			Template:                   p,
-			Attributes:                 attr,
-			AllowAttributeSubstitution: true,
-		})
-		if err != nil {
-			return err
-		}
-		delete(ds.Paths, k)
-		maps.Copy(ds.Paths, paths)
-	}
+	//handle data source paths and data paths substitution
+	ds.Paths = pathMapSubstitute(ds.Paths, attr, lookupEnv, ccVars, jsonPathField(location, "paths"), c)
+	ds.DataPaths = pathMapSubstitute(ds.DataPaths, attr, lookupEnv, ccVars, jsonPathField(location, "data_paths"), c)
+}
 
-	//handle data source data paths substitution
-	for k, p := range ds.DataPaths {
-		paths, err := parameterSubstitute(paramSubInput{
+// pathMapSubstitute substitutes and inflates a data source paths or data paths map
+func pathMapSubstitute(paths map[string]string, attr map[string]any, lookupEnv EnvLookup, ccVars map[string]string, location string, c *substitutionCollector) map[string]string {
+	for _, k := range sortedKeys(paths) {
+		p := paths[k]
+		input := paramSubInput{
 			TemplateKey:                k,
 			Template:                   p,
 			Attributes:                 attr,
 			AllowAttributeSubstitution: true,
-		})
+			LookupEnv:                  lookupEnv,
+			CcVars:                     ccVars,
+		}
+		newpaths, err := parameterSubstitute(input)
 		if err != nil {
-			return err
+			c.unresolved(jsonPathField(location, k), p, err, true)
+			continue
 		}
-		delete(ds.Paths, k)
-		maps.Copy(ds.Paths, paths)
+		c.check(jsonPathField(location, k), input)
+		delete(paths, k)
+		maps.Copy(paths, newpaths)
 	}
-
-	return nil
+	return paths
 }
 
-func handleSliceSub[T any](val []T, pm *PluginManager, attrSub bool) []any {
+func handleSliceSub[T any](val []T, pm *PluginManager, attrSub bool, ccVars map[string]string, location string, c *substitutionCollector) []any {
 	newslice := []any{}
-	for _, v := range val {
+	for i, v := range val {
 		if stringv, ok := any(v).(string); ok {
-			newvals, err := parameterSubstitute(paramSubInput{
+			input := paramSubInput{
 				TemplateKey:                "",
 				Template:                   stringv,
 				Attributes:                 pm.Attributes,
 				AllowAttributeSubstitution: attrSub,
-			})
-			if err == nil {
-				for _, v := range newvals {
-					newslice = append(newslice, v)
-				}
+				LookupEnv:                  pm.envLookup(),
+				CcVars:                     ccVars,
+			}
+			newvals, err := substituteTemplate(input)
+			if err != nil {
+				//keep the unresolved template in place
+				c.unresolved(jsonPathIndex(location, i), stringv, err, false)
+				newslice = append(newslice, v)
+				continue
+			}
+			c.check(jsonPathIndex(location, i), input)
+			//inflated values keep the order of the referenced array
+			for _, nv := range newvals {
+				newslice = append(newslice, nv.value)
 			}
 		} else {
 			newslice = append(newslice, v)
@@ -448,12 +1056,24 @@ func handleSliceSub[T any](val []T, pm *PluginManager, attrSub bool) []any {
 	return newslice
 }
 
+// PathSegment is one step of the path following a substitution reference name.
+// Key segments (.name, ['name'] or ["name"]) set Key and an Index of -1,
+// index segments ([0]) set Index and inflation segments ([]) set Inflate.
+type PathSegment struct {
+	Key     string
+	Index   int
+	Inflate bool
+}
+
 type EmbeddedVar struct {
-	Type         string
-	Varname      string
-	IsArrayOrMap bool
-	ArrayIndex   int
-	MapIndex     string
+	Type            string
+	Varname         string
+	Path            []PathSegment
+	HasDefault      bool   //{TYPE::NAME:-default}
+	Default         string //value used when the reference is unset or empty
+	Required        bool   //{TYPE::NAME:?message}
+	RequiredMessage string
+	Filters         []SubstitutionFilter //{TYPE::NAME|filter:arg}
 }
 
 //func handleSubstitution()
@@ -463,15 +1083,36 @@ type paramSubInput struct {
 	Template                   string
 	Attributes                 map[string]any
 	AllowAttributeSubstitution bool
+	LookupEnv                  EnvLookup         //optional - defaults to os.LookupEnv
+	CcVars                     map[string]string //optional - values of the CC:: namespace
 }
 
+// parameterSubstitute substitutes a template.  Inflated references expand the template into
+// one parameter for each value, keyed by the template key and the value.
 // @TODO how to handle case when array values are not annotated as arrays?  should concat!
 func parameterSubstitute(input paramSubInput) (map[string]string, error) {
-
-	//create a map for expanding the template into our output
-	output := map[string]string{
-		input.TemplateKey: input.Template,
+	substituted, err := substituteTemplate(input)
+	if err != nil {
+		return nil, err
+	}
+	output := make(map[string]string, len(substituted))
+	for _, sv := range substituted {
+		output[sv.key] = sv.value
 	}
+	return output, nil
+}
+
+// substitutedValue is a template expanded by substituteTemplate
+type substitutedValue struct {
+	key   string
+	value string
+}
+
+// substituteTemplate substitutes a template, keeping inflated values in the order of the referenced values
+func substituteTemplate(input paramSubInput) ([]substitutedValue, error) {
+
+	//create a list for expanding the template into our output
+	output := []substitutedValue{{input.TemplateKey, input.Template}}
 
 	result := substitutionRegex.FindAllStringSubmatch(input.Template, -1)
 	for _, match := range result {
@@ -488,133 +1129,253 @@ func parameterSubstitute(input paramSubInput) (map[string]string, error) {
 			//skip
 			continue
 		}
-		val, err := getSubstitutionVal(eVars, input.Attributes)
+		vals, inflate, err := getSubstitutionVal(eVars, input)
 		if err != nil {
 			return nil, err
 		}
-		vof := reflect.ValueOf(val)
-		switch vof.Kind() {
-		case reflect.String:
-			for outputkey, outputline := range output {
-				output[outputkey] = strings.Replace(outputline, match[0], val.(string), -1)
-			}
-		case reflect.Slice:
-			//-1 -> inflate the entire array into the parameter
-			if eVars.ArrayIndex == -1 && eVars.IsArrayOrMap {
-				newoutput := make(map[string]string)
-				for outputkey, outputline := range output {
-					for i := 0; i < vof.Len(); i++ {
-						element := vof.Index(i)
-						strval := fmt.Sprintf("%v", element.Interface())
-						newoutput[outputkey+"-"+strval] = strings.Replace(outputline, match[0], strval, -1)
-					}
-				}
-				output = newoutput
-
-				//have an index.  substitute on the index
-			} else if eVars.ArrayIndex > -1 && eVars.IsArrayOrMap {
-				for outputkey, outputline := range output {
-					element := vof.Index(eVars.ArrayIndex)
-					strval := fmt.Sprintf("%v", element.Interface())
-					output[outputkey] = strings.Replace(outputline, match[0], strval, -1)
-				}
 
-				//have a slice but the user referenced the var without array semantics
-				//concatonate the slice into csv and substitute the csv string
-			} else {
-				//concat slice into csv and treat as string
-				for outputkey, outputline := range output {
-					builder := strings.Builder{}
-					for i := 0; i < vof.Len(); i++ {
-						if i > 0 {
-							builder.WriteString(",")
-						}
-						element := vof.Index(i)
-						strval := fmt.Sprintf("%v", element.Interface())
-						builder.WriteString(strval)
-					}
-					output[outputkey] = strings.Replace(outputline, match[0], builder.String(), -1)
-				}
+		strvals := make([]string, len(vals))
+		for i, v := range vals {
+			strvals[i], err = filterValue(v, eVars.Filters)
+			if err != nil {
+				return nil, err
 			}
-		case reflect.Map:
-			//no map index, so inflate the entire map into the parameter
-			if eVars.MapIndex == "" && eVars.IsArrayOrMap {
-				newoutput := make(map[string]string)
-				for outputkey, outputline := range output {
-					for _, key := range vof.MapKeys() {
-						element := vof.MapIndex(key)
-						strval := fmt.Sprintf("%v", element.Interface())
-						newoutput[outputkey+"-"+strval] = strings.Replace(outputline, match[0], strval, -1)
-					}
-				}
-				output = newoutput
-
-				//have a map index, so get the map value and substitute
-			} else {
-				for outputkey, outputline := range output {
-					element := vof.MapIndex(reflect.ValueOf(eVars.MapIndex))
-					strval := fmt.Sprintf("%v", element.Interface())
-					output[outputkey] = strings.Replace(outputline, match[0], strval, -1)
+		}
+
+		//inflate each value into a separate parameter
+		if inflate {
+			newoutput := make([]substitutedValue, 0, len(output)*len(strvals))
+			for _, o := range output {
+				for _, strval := range strvals {
+					newoutput = append(newoutput, substitutedValue{o.key + "-" + strval, strings.Replace(o.value, match[0], strval, -1)})
 				}
 			}
-		default:
-			//handle same as a string, but coerce values to a string
-			for outputkey, outputline := range output {
-				output[outputkey] = strings.Replace(outputline, match[0], fmt.Sprintf("%v", val), -1)
-			}
+			output = newoutput
+			continue
 		}
-		if err != nil {
-			return nil, err
+
+		strval := strvals[0]
+		for i := range output {
+			output[i].value = strings.Replace(output[i].value, match[0], strval, -1)
 		}
 	}
 	return output, nil
 }
 
-func getSubstitutionVal(evar EmbeddedVar, payloadAttr map[string]any) (any, error) {
-	var returnval any
+// getSubstitutionVal resolves a template reference and its path.
+// The values are inflated into separate parameters when inflate is true.
+// A reference that is unset or empty resolves to its default value when it has one
+// and returns an error wrapping ErrRequiredSubstitution when it is marked as required.
+func getSubstitutionVal(evar EmbeddedVar, input paramSubInput) (vals []any, inflate bool, err error) {
+	root, err := substitutionRoot(evar, input)
+	if err == nil {
+		vals, inflate, err = resolveSubstitutionPath(root, evar.Path)
+		if err == nil {
+			return vals, inflate, nil
+		}
+		err = fmt.Errorf("invalid reference %s::%s: %w", evar.Type, evar.Varname, err)
+	}
+	switch {
+	case evar.Required:
+		msg := evar.RequiredMessage
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, false, fmt.Errorf("%w: %s::%s: %s", ErrRequiredSubstitution, evar.Type, evar.Varname, msg)
+	case evar.HasDefault && evar.Type == ParmamSubEnv:
+		//env defaults are handled the same as env values
+		return resolveSubstitutionPath(strings.Split(evar.Default, ","), evar.Path)
+	case evar.HasDefault:
+		return []any{evar.Default}, false, nil
+	case evar.hasFilter(filterDefault):
+		//the default filter replaces the empty value
+		return []any{""}, false, nil
+	}
+	return nil, false, err
+}
+
+// substitutionRoot returns the value named by a template reference before its path is applied
+func substitutionRoot(evar EmbeddedVar, input paramSubInput) (any, error) {
+	//empty values are only treated as unset when the reference has a default or is required
+	modified := evar.HasDefault || evar.Required
 	switch evar.Type {
-	case "ENV":
+	case ParmamSubEnv:
+		lookupEnv := input.LookupEnv
+		if lookupEnv == nil {
+			lookupEnv = os.LookupEnv
+		}
 		//get the env var then try and split it with a comma separator
 		//supported env values are single vals "1" or csv vals "one,two,three"
-		returnval = strings.Split(os.Getenv(evar.Varname), ",")
-	case "ATTR":
-		val, ok := payloadAttr[evar.Varname]
+		envval, ok := lookupEnv(evar.Varname)
+		if modified && (!ok || envval == "") {
+			return nil, fmt.Errorf("environment variable %s is not set", evar.Varname)
+		}
+		return strings.Split(envval, ","), nil
+	case ParamSubAttr:
+		val, ok := input.Attributes[evar.Varname]
 		if !ok {
 			return nil, fmt.Errorf("invalid attribute name: %s", evar.Varname)
 		}
-		returnval = val
+		return val, nil
+	case ParamSubCc:
+		val, ok := input.CcVars[evar.Varname]
+		if !ok || (modified && val == "") {
+			return nil, fmt.Errorf("invalid CC variable: %s", evar.Varname)
+		}
+		return val, nil
 	}
-	return returnval, nil
+	return nil, fmt.Errorf("unsupported substitution type: %s", evar.Type)
 }
 
-func matchToEmbeddedVars(match []string) EmbeddedVar {
+// resolveSubstitutionPath walks path from root.
+// Each inflation segment expands the current values into their elements (slices) or values (maps),
+// so inflation can be used at any level of the path.
+func resolveSubstitutionPath(root any, path []PathSegment) ([]any, bool, error) {
+	vals := []any{root}
+	inflate := false
+	for _, seg := range path {
+		next := make([]any, 0, len(vals))
+		for _, v := range vals {
+			if seg.Inflate {
+				elements, err := inflateValue(v)
+				if err != nil {
+					return nil, false, err
+				}
+				next = append(next, elements...)
+				continue
+			}
+			element, err := pathElement(v, seg)
+			if err != nil {
+				return nil, false, err
+			}
+			next = append(next, element)
+		}
+		inflate = inflate || seg.Inflate
+		vals = next
+	}
+	if len(vals) == 0 && !inflate {
+		return nil, false, errors.New("reference has no value")
+	}
+	return vals, inflate, nil
+}
+
+func pathElement(v any, seg PathSegment) (any, error) {
+	vof := reflect.ValueOf(v)
+	switch vof.Kind() {
+	case reflect.Map:
+		if vof.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", vof.Type().Key())
+		}
+		key := seg.Key
+		if seg.Index > -1 {
+			key = strconv.Itoa(seg.Index)
+		}
+		element := vof.MapIndex(reflect.ValueOf(key).Convert(vof.Type().Key()))
+		if !element.IsValid() {
+			return nil, fmt.Errorf("key %q not found", key)
+		}
+		return element.Interface(), nil
+	case reflect.Slice, reflect.Array:
+		index := seg.Index
+		if index < 0 {
+			i, err := strconv.Atoi(seg.Key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q", seg.Key)
+			}
+			index = i
+		}
+		if index >= vof.Len() {
+			return nil, fmt.Errorf("index %d out of range", index)
+		}
+		return vof.Index(index).Interface(), nil
+	}
+	return nil, fmt.Errorf("can not index a %T value", v)
+}
+
+func inflateValue(v any) ([]any, error) {
+	vof := reflect.ValueOf(v)
+	switch vof.Kind() {
+	case reflect.Slice, reflect.Array:
+		elements := make([]any, vof.Len())
+		for i := range elements {
+			elements[i] = vof.Index(i).Interface()
+		}
+		return elements, nil
+	case reflect.Map:
+		keys := vof.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+		})
+		elements := make([]any, len(keys))
+		for i, key := range keys {
+			elements[i] = vof.MapIndex(key).Interface()
+		}
+		return elements, nil
+	}
+	return nil, fmt.Errorf("can not inflate a %T value", v)
+}
+
+// substitutionString formats a substituted value.
+// slices referenced without array semantics are concatenated into a csv string.
+func substitutionString(v any) string {
+	if str, ok := v.(string); ok {
+		return str
+	}
+	vof := reflect.ValueOf(v)
+	if vof.Kind() == reflect.Slice || vof.Kind() == reflect.Array {
+		builder := strings.Builder{}
+		for i := 0; i < vof.Len(); i++ {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(fmt.Sprintf("%v", vof.Index(i).Interface()))
+		}
+		return builder.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
 
+func parseSubstitutionPath(path string) []PathSegment {
+	segments := []PathSegment{}
+	for _, m := range pathSegmentRegex.FindAllStringSubmatchIndex(path, -1) {
+		group := func(n int) (string, bool) {
+			if m[2*n] < 0 {
+				return "", false
+			}
+			return path[m[2*n]:m[2*n+1]], true
+		}
+		seg := PathSegment{Index: -1}
+		if key, ok := group(1); ok {
+			seg.Key = key
+		} else if index, ok := group(2); ok {
+			seg.Index, _ = strconv.Atoi(index)
+		} else if key, ok := group(3); ok {
+			seg.Key = key
+		} else if key, ok := group(4); ok {
+			seg.Key = key
+		} else {
+			seg.Inflate = true
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+func matchToEmbeddedVars(match []string) EmbeddedVar {
 	ev := EmbeddedVar{
-		Type:       match[1],
-		Varname:    match[2],
-		ArrayIndex: -1,
+		Type:    match[1],
+		Varname: match[2],
+		Path:    parseSubstitutionPath(match[3]),
+		Filters: parseSubstitutionFilters(match[6]),
 	}
 
-	// If any of groups 3..6 matched, it's an array/map reference.
-	switch {
-	case match[3] != "":
-		// matched [] (empty index)
-		ev.IsArrayOrMap = true
-	case match[4] != "":
-		// matched [0] numeric array index
-		ev.IsArrayOrMap = true
-		i, err := strconv.Atoi(match[4])
-		if err == nil {
-			ev.ArrayIndex = i
-		}
-	case match[5] != "":
-		// matched ['key'] single-quoted map key
-		ev.IsArrayOrMap = true
-		ev.MapIndex = match[5]
-	case match[6] != "":
-		// matched ["key"] double-quoted map key
-		ev.IsArrayOrMap = true
-		ev.MapIndex = match[6]
+	switch match[4] {
+	case substitutionDefaultOp:
+		ev.HasDefault = true
+		ev.Default = match[5]
+	case substitutionRequiredOp:
+		ev.Required = true
+		ev.RequiredMessage = match[5]
 	}
 	return ev
 }
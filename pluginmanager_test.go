@@ -1,8 +1,18 @@
 package cc
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestSubstituteMapVariablesEnvOnly(t *testing.T) {
@@ -113,3 +123,637 @@ func TestSubstituteMapVariables(t *testing.T) {
 		t.Fatalf("expected: %v found %v", expectedResult, pm.Attributes)
 	}
 }
+
+// initTestPluginManager initializes a plugin manager with an empty payload,
+// a local store and an injected environment
+func initTestPluginManager(t *testing.T, opts ...Option) (*PluginManager, error) {
+	t.Setenv(FsbRootPath, t.TempDir())
+	store, err := NewFSBCcStore("test-manifest", "test-payload")
+	if err != nil {
+		return nil, err
+	}
+	env := map[string]string{
+		"TESTV3":  "98765432",
+		"V5TEST1": "this is a test",
+	}
+	opts = append([]Option{WithCcStore(store), WithPayload(Payload{}), WithEnv(env)}, opts...)
+	return InitPluginManager(opts...)
+}
+
+func TestSubstituteMapVariablesWithEnvOption(t *testing.T) {
+	t.Setenv("TESTV3", "process value")
+	pm, err := initTestPluginManager(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := map[string]any{
+		"val3": "this is a {ENV::TESTV3}",
+		"val5": map[string]any{"v5test2": "this is a test of {ENV::V5TEST1}"},
+	}
+	pm.substituteMapVariables(attrs, false)
+	expected := map[string]any{
+		"val3": "this is a 98765432",
+		"val5": map[string]any{"v5test2": "this is a test of this is a test"},
+	}
+	if !reflect.DeepEqual(attrs, expected) {
+		t.Fatalf("expected the injected environment to be substituted: %v found %v", expected, attrs)
+	}
+}
+
+type blockingTestAction struct {
+	ActionRunnerBase
+}
+
+func (a *blockingTestAction) Run() error {
+	return a.RunContext(a.Context())
+}
+
+func (a *blockingTestAction) RunContext(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newTestPluginManager(actions ...Action) *PluginManager {
+	return &PluginManager{
+		Logger:  NewCcLogger(CcLoggerInput{"test-manifest", "test-payload", nil}),
+		Payload: Payload{Actions: actions},
+	}
+}
+
+func TestRunActionsTimeout(t *testing.T) {
+	ActionRegistry.RegisterAction("blocking-timeout", &blockingTestAction{})
+	pm := newTestPluginManager(Action{
+		Name: "blocking-timeout",
+		IOManager: IOManager{
+			Attributes: PayloadAttributes{ActionTimeoutAttr: "50ms"},
+		},
+	})
+	err := pm.RunActions()
+	if !errors.Is(err, ErrActionInterrupted) {
+		t.Fatalf("expected an interrupted action error, found %v", err)
+	}
+	if !strings.Contains(err.Error(), "blocking-timeout") {
+		t.Fatalf("expected the error to name the interrupted action: %s", err)
+	}
+}
+
+type plainTimeoutTestAction struct {
+	ActionRunnerBase
+	release     chan struct{}
+	runs        *atomic.Int32
+	finished    *atomic.Bool
+	teardowns   *atomic.Int32
+	setupCtxSet *atomic.Bool
+	teardownCtx *atomic.Bool
+}
+
+func (a *plainTimeoutTestAction) Setup() error {
+	_, hasDeadline := a.Context().Deadline()
+	a.setupCtxSet.Store(hasDeadline)
+	return nil
+}
+
+func (a *plainTimeoutTestAction) Run() error {
+	a.runs.Add(1)
+	<-a.release
+	a.finished.Store(true)
+	return nil
+}
+
+func (a *plainTimeoutTestAction) Teardown() error {
+	a.teardowns.Add(1)
+	_, hasDeadline := a.Context().Deadline()
+	a.teardownCtx.Store(hasDeadline && a.Context().Err() == nil)
+	return nil
+}
+
+func TestRunActionsPlainRunnerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+	runs, finished, teardowns := &atomic.Int32{}, &atomic.Bool{}, &atomic.Int32{}
+	setupCtxSet, teardownCtx := &atomic.Bool{}, &atomic.Bool{}
+	RegisterActionFactory("plain-timeout", func(pm *PluginManager, action Action) (ActionRunner, error) {
+		return &plainTimeoutTestAction{ActionRunnerBase{ActionName: action.Name, PluginManager: pm, Action: action}, release, runs, finished, teardowns, setupCtxSet, teardownCtx}, nil
+	})
+	pm := newTestPluginManager(Action{
+		Name:      "plain-timeout",
+		IOManager: IOManager{Attributes: PayloadAttributes{ActionTimeoutAttr: "20ms"}},
+		Retry:     &RetryPolicy{MaxAttempts: 3, InitialBackoff: "1ms"},
+	})
+	err := pm.RunActions()
+	if !errors.Is(err, ErrActionInterrupted) {
+		t.Fatalf("expected an interrupted action error, found %v", err)
+	}
+	if !setupCtxSet.Load() {
+		t.Fatal("expected the action timeout to be set before Setup")
+	}
+	if !finished.Load() {
+		t.Fatal("expected RunActions to wait for the plain runner to return")
+	}
+	if runs.Load() != 1 {
+		t.Fatalf("expected an overrun plain runner not to be retried, found %d runs", runs.Load())
+	}
+	if teardowns.Load() != 1 {
+		t.Fatalf("expected teardown after Run returned, found %d teardowns", teardowns.Load())
+	}
+	if !teardownCtx.Load() {
+		t.Fatal("expected Teardown to get a live context bounded by the action timeout")
+	}
+}
+
+func TestRunActionsContextCancel(t *testing.T) {
+	ActionRegistry.RegisterAction("blocking-cancel", &blockingTestAction{})
+	pm := newTestPluginManager(Action{Name: "blocking-cancel"})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := pm.RunActionsContext(ctx)
+	if !errors.Is(err, ErrActionInterrupted) {
+		t.Fatalf("expected an interrupted action error, found %v", err)
+	}
+}
+
+type recordingTestAction struct {
+	ActionRunnerBase
+}
+
+var recordedActions []string
+
+func (a *recordingTestAction) Run() error {
+	recordedActions = append(recordedActions, a.ActionName)
+	return nil
+}
+
+type unboundTestAction struct{}
+
+func (a *unboundTestAction) Run() error { return nil }
+
+func TestRegisterActionType(t *testing.T) {
+	RegisterActionType[recordingTestAction]("typed-action")
+	recordedActions = nil
+	pm := newTestPluginManager(Action{Name: "typed-action"})
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recordedActions, []string{"typed-action"}) {
+		t.Fatalf("expected typed-action to run, found %v", recordedActions)
+	}
+}
+
+func TestRegisterActionFactoryError(t *testing.T) {
+	RegisterActionFactory("failing-factory", func(pm *PluginManager, a Action) (ActionRunner, error) {
+		return nil, errors.New("missing model file")
+	})
+	pm := newTestPluginManager(Action{Name: "failing-factory"})
+	err := pm.RunActions()
+	if err == nil || !strings.Contains(err.Error(), "missing model file") {
+		t.Fatalf("expected the factory error, found %v", err)
+	}
+}
+
+func TestReflectedRunnerWithoutBase(t *testing.T) {
+	ActionRegistry.RegisterAction("unbound-action", &unboundTestAction{})
+	pm := newTestPluginManager(Action{Name: "unbound-action"})
+	if err := pm.RunActions(); err == nil {
+		t.Fatal("expected an error for a runner without ActionRunnerBase")
+	}
+}
+
+type flakyTestAction struct {
+	ActionRunnerBase
+	failures int
+	attempts *int
+	class    string
+}
+
+func (a *flakyTestAction) Run() error {
+	*a.attempts++
+	if *a.attempts <= a.failures {
+		return ClassifyError(a.class, fmt.Errorf("attempt %d failed", *a.attempts))
+	}
+	return nil
+}
+
+func registerFlakyAction(name string, failures int, class string, attempts *int) {
+	RegisterActionFactory(name, func(pm *PluginManager, a Action) (ActionRunner, error) {
+		runner := &flakyTestAction{failures: failures, attempts: attempts, class: class}
+		runner.bind(pm, a)
+		return runner, nil
+	})
+}
+
+func TestRunActionsRetry(t *testing.T) {
+	attempts := 0
+	registerFlakyAction("flaky-transient", 2, ErrorClassTransient, &attempts)
+	pm := newTestPluginManager(Action{
+		Name:  "flaky-transient",
+		Retry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: "1ms", RetryOn: []string{ErrorClassTransient}},
+	})
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, found %d", attempts)
+	}
+}
+
+func TestRunActionsRetryExhausted(t *testing.T) {
+	attempts := 0
+	registerFlakyAction("flaky-exhausted", 5, ErrorClassTransient, &attempts)
+	pm := newTestPluginManager(Action{Name: "flaky-exhausted"})
+	pm.config.MaxRetry = 1
+	pm.config.RetryInitialBackoff = time.Millisecond
+	err := pm.RunActions()
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || len(retryErr.Attempts) != 2 {
+		t.Fatalf("expected a retry error with 2 attempts, found %v", err)
+	}
+}
+
+func TestRunActionsRetryNotRetryable(t *testing.T) {
+	attempts := 0
+	registerFlakyAction("flaky-fatal", 5, "fatal", &attempts)
+	pm := newTestPluginManager(Action{
+		Name:  "flaky-fatal",
+		Retry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: "1ms", RetryOn: []string{ErrorClassTransient}},
+	})
+	if err := pm.RunActions(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt, found %d", attempts)
+	}
+}
+
+type lifecycleTestAction struct {
+	ActionRunnerBase
+	calls       *[]string
+	validateErr error
+	runErr      error
+}
+
+func (a *lifecycleTestAction) Validate() error {
+	*a.calls = append(*a.calls, a.ActionName+":validate")
+	return a.validateErr
+}
+
+func (a *lifecycleTestAction) Setup() error {
+	*a.calls = append(*a.calls, a.ActionName+":setup")
+	return nil
+}
+
+func (a *lifecycleTestAction) Run() error {
+	*a.calls = append(*a.calls, a.ActionName+":run")
+	return a.runErr
+}
+
+func (a *lifecycleTestAction) Teardown() error {
+	*a.calls = append(*a.calls, a.ActionName+":teardown")
+	return nil
+}
+
+func registerLifecycleAction(name string, calls *[]string, validateErr error, runErr error) {
+	RegisterActionFactory(name, func(pm *PluginManager, a Action) (ActionRunner, error) {
+		runner := &lifecycleTestAction{calls: calls, validateErr: validateErr, runErr: runErr}
+		runner.bind(pm, a)
+		return runner, nil
+	})
+}
+
+func TestRunActionsLifecycle(t *testing.T) {
+	calls := []string{}
+	runErr := errors.New("compute failed")
+	registerLifecycleAction("lifecycle-1", &calls, nil, nil)
+	registerLifecycleAction("lifecycle-2", &calls, nil, runErr)
+	pm := newTestPluginManager(Action{Name: "lifecycle-1"}, Action{Name: "lifecycle-2"})
+	err := pm.RunActions()
+	var actionErr *ActionError
+	if !errors.As(err, &actionErr) || actionErr.Phase != PhaseRun || !errors.Is(err, runErr) {
+		t.Fatalf("expected a run phase error, found %v", err)
+	}
+	expected := []string{
+		"lifecycle-1:validate", "lifecycle-2:validate",
+		"lifecycle-1:setup", "lifecycle-1:run", "lifecycle-1:teardown",
+		"lifecycle-2:setup", "lifecycle-2:run", "lifecycle-2:teardown",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected: %v found %v", expected, calls)
+	}
+}
+
+func TestRunActionsValidateFailsFast(t *testing.T) {
+	calls := []string{}
+	registerLifecycleAction("validate-ok", &calls, nil, nil)
+	registerLifecycleAction("validate-bad", &calls, errors.New("missing plan attribute"), nil)
+	pm := newTestPluginManager(Action{Name: "validate-ok"}, Action{Name: "validate-bad"})
+	err := pm.RunActions()
+	var actionErr *ActionError
+	if !errors.As(err, &actionErr) || actionErr.Phase != PhaseValidate {
+		t.Fatalf("expected a validate phase error, found %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"validate-ok:validate", "validate-bad:validate"}) {
+		t.Fatalf("no action should run after a failed validation: %v", calls)
+	}
+}
+
+func TestRunActionsUnregistered(t *testing.T) {
+	calls := []string{}
+	registerLifecycleAction("registered-action", &calls, nil, nil)
+	pm := newTestPluginManager(Action{Name: "registered-action"}, Action{Name: "misspeled-action"})
+	err := pm.RunActions()
+	if err == nil || !strings.Contains(err.Error(), "misspeled-action") || !strings.Contains(err.Error(), "registered-action") {
+		t.Fatalf("expected an unregistered action error, found %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("no action should run in strict mode: %v", calls)
+	}
+
+	pm.config.AllowUnregisteredActions = true
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(calls, "registered-action:run") {
+		t.Fatalf("expected registered-action to run in lenient mode: %v", calls)
+	}
+}
+
+type resultTestAction struct {
+	ActionRunnerBase
+}
+
+func (a *resultTestAction) Run() error {
+	return nil
+}
+
+func (a *resultTestAction) RunResult(ctx context.Context) (ActionResult, error) {
+	return ActionResult{
+		Outputs: []OutputReference{{DataSource: "grid", PathKey: "default"}},
+		Results: map[string]any{"cells": 42},
+	}, nil
+}
+
+func TestRunActionsSummary(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv(FsbRootPath, root)
+	store, err := NewFSBCcStore("summary-manifest", "summary-payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterActionType[resultTestAction]("result-action")
+	pm := newTestPluginManager(
+		Action{Name: "result-action"},
+		Action{Name: "unregistered-summary-action"},
+	)
+	pm.ccStore = store
+	pm.EventIdentifier = "7"
+	pm.config.AllowUnregisteredActions = true
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "summary-manifest", "run-summary-7.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := RunSummary{}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Status != SUCCEEDED || len(summary.Actions) != 2 {
+		t.Fatalf("unexpected summary: %s", data)
+	}
+	result := summary.Actions[0]
+	if result.Status != ActionSucceeded || result.Results["cells"] != float64(42) || len(result.Outputs) != 1 || result.Start == nil || result.End == nil {
+		t.Fatalf("unexpected action result: %+v", result)
+	}
+	if summary.Actions[1].Status != ActionSkipped {
+		t.Fatalf("expected the unregistered action to be skipped: %+v", summary.Actions[1])
+	}
+	if strings.Contains(string(data), `"start":"0001-01-01`) {
+		t.Fatalf("expected the skipped action to omit its times: %s", data)
+	}
+}
+
+func TestInitPluginManagerOptions(t *testing.T) {
+	payload := Payload{
+		IOManager: IOManager{
+			Attributes: PayloadAttributes{"region": "{ENV::REGION}"},
+		},
+	}
+	managers := []*PluginManager{}
+	for _, region := range []string{"us-east-1", "us-west-2"} {
+		pm, err := initTestPluginManager(t,
+			WithPayload(payload),
+			WithEnv(map[string]string{"REGION": region, CcManifestId: "manifest-" + region}),
+			WithEventIdentifier(region),
+			WithStoreRegistry(DataStoreTypeRegistryMap{}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		managers = append(managers, pm)
+	}
+	for _, pm := range managers {
+		if pm.Attributes["region"] != pm.EventIdentifier || pm.manifestId != "manifest-"+pm.EventIdentifier {
+			t.Fatalf("manager state leaked between managers: %v %s %s", pm.Attributes, pm.EventIdentifier, pm.manifestId)
+		}
+	}
+}
+
+func TestInitPluginManagerEnvLookupStore(t *testing.T) {
+	//the process environment points at a store that can not be created
+	t.Setenv(CcStoreType, "bogus")
+	t.Setenv(FsbRootPath, t.TempDir())
+
+	root := t.TempDir()
+	payloadDir := filepath.Join(root, "lookup-payload")
+	if err := os.MkdirAll(payloadDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(payloadDir, payloadFileName), []byte(`{"attributes":{"model":"ras"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		CcStoreType:        string(FSB),
+		FsbRootPath:        root,
+		CcManifestId:       "lookup-manifest",
+		CcPayloadId:        "lookup-payload",
+		CcEventIdentifier:  "1",
+		CcPayloadFormatted: "true",
+	}
+	pm, err := InitPluginManager(WithEnv(env), WithStoreRegistry(DataStoreTypeRegistryMap{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pm.Attributes["model"] != "ras" {
+		t.Fatalf("expected the payload from the injected store root, found %v", pm.Attributes)
+	}
+	if err := pm.ccStore.SetPayload(pm.Payload); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(payloadDir, payloadFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\n  ") {
+		t.Fatalf("expected the payload to be formatted by the injected env, found %s", data)
+	}
+}
+
+func TestCcSubstitution(t *testing.T) {
+	registry := DataStoreTypeRegistryMap{}
+	registry.Register("TEST", struct{}{})
+	payload := Payload{
+		IOManager: IOManager{
+			Attributes: PayloadAttributes{"run": "{CC::EVENT_ID}"},
+			Stores:     []DataStore{{Name: "local", StoreType: "TEST", Parameters: PayloadAttributes{"root": "/{CC::MANIFEST_ID}"}}},
+		},
+		Actions: []Action{{
+			Name: "post-process",
+			IOManager: IOManager{
+				Attributes: PayloadAttributes{"label": "{CC::ACTION}-{CC::EVENT_NUMBER}"},
+				Stores:     []DataStore{{Name: "scratch", StoreType: "TEST", Parameters: PayloadAttributes{"root": "/{CC::ACTION}/{CC::EVENT_ID}"}}},
+				Outputs:    []DataSource{{Name: "results", StoreName: "local", Paths: map[string]string{"default": "{CC::PAYLOAD_ID}/{CC::ACTION}.hdf"}}},
+			},
+		}},
+	}
+	pm, err := initTestPluginManager(t, WithPayload(payload), WithStoreRegistry(registry), WithEnv(map[string]string{
+		CcManifestId:      "manifest-1",
+		CcPayloadId:       "payload-1",
+		CcEventIdentifier: "42",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	action := pm.Actions[0]
+	results := map[string]any{
+		"payload attribute":  pm.Attributes["run"],
+		"store param":        pm.Stores[0].Parameters["root"],
+		"action attribute":   action.Attributes["label"],
+		"data source path":   action.Outputs[0].Paths["default"],
+		"action store param": action.Stores[0].Parameters["root"],
+	}
+	expected := map[string]any{
+		"payload attribute":  "42",
+		"store param":        "/manifest-1",
+		"action attribute":   "post-process-42",
+		"data source path":   "payload-1/post-process.hdf",
+		"action store param": "/post-process/42",
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected %v, found %v", expected, results)
+	}
+}
+
+func TestSubstitutionDefaultsAndRequired(t *testing.T) {
+	registry := DataStoreTypeRegistryMap{}
+	registry.Register("TEST", struct{}{})
+	newPayload := func() Payload {
+		return Payload{
+			IOManager: IOManager{
+				Attributes: PayloadAttributes{
+					"region": "{ENV::REGION:-us-east-1}",
+					"bucket": "{ENV::BUCKET:?set BUCKET to the model bucket}",
+				},
+				Stores: []DataStore{{Name: "local", StoreType: "TEST", Parameters: PayloadAttributes{"root": "/{CC::MANIFEST_ID:-adhoc}"}}},
+			},
+			Actions: []Action{{
+				Name: "compute",
+				IOManager: IOManager{
+					Attributes: PayloadAttributes{"threads": "{ATTR::max_threads:-4}", "missing": "{ATTR::max_threads}"},
+				},
+			}},
+		}
+	}
+
+	pm, err := initTestPluginManager(t, WithPayload(newPayload()), WithStoreRegistry(registry), WithEnv(map[string]string{"BUCKET": "models"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := map[string]any{
+		"region":  pm.Attributes["region"],
+		"bucket":  pm.Attributes["bucket"],
+		"root":    pm.Stores[0].Parameters["root"],
+		"threads": pm.Actions[0].Attributes["threads"],
+		"missing": pm.Actions[0].Attributes["missing"],
+	}
+	expected := map[string]any{
+		"region":  "us-east-1",
+		"bucket":  "models",
+		"root":    "/adhoc",
+		"threads": "4",
+		"missing": "{ATTR::max_threads}",
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected %v, found %v", expected, results)
+	}
+
+	_, err = initTestPluginManager(t, WithPayload(newPayload()), WithStoreRegistry(registry), WithEnv(map[string]string{}))
+	if !errors.Is(err, ErrRequiredSubstitution) {
+		t.Fatalf("expected a required substitution error, found %v", err)
+	}
+	var serr *SubstitutionError
+	if !errors.As(err, &serr) || serr.Location != "$.attributes.bucket" {
+		t.Fatalf("expected the error to locate $.attributes.bucket, found %v", err)
+	}
+	if !strings.Contains(err.Error(), "set BUCKET to the model bucket") {
+		t.Fatalf("expected the error to include the required message, found %v", err)
+	}
+}
+
+func TestNestedPathSubstitution(t *testing.T) {
+	attrs := map[string]any{}
+	err := json.Unmarshal([]byte(`{
+		"fruit": {"tropical": ["mango", "papaya"], "apple1": "gala"},
+		"colors": {"bold": {"red1": "crimson"}},
+		"basins": {"1": "basin-z", "2": "basin-y"},
+		"regions": [
+			{"name": "north", "basins": ["a", "b"]},
+			{"name": "south", "basins": ["c"]}
+		]
+	}`), &attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		template string
+		expected map[string]string
+	}{
+		{"{ATTR::colors.bold.red1}", map[string]string{"p": "crimson"}},
+		{"{ATTR::fruit.tropical[0]}", map[string]string{"p": "mango"}},
+		{"{ATTR::fruit.tropical.1}", map[string]string{"p": "papaya"}},
+		{"{ATTR::fruit['apple1']}", map[string]string{"p": "gala"}},
+		{"{ATTR::basins['1']}", map[string]string{"p": "basin-z"}},
+		{"{ATTR::basins.2}", map[string]string{"p": "basin-y"}},
+		{"{ATTR::fruit.tropical}", map[string]string{"p": "mango,papaya"}},
+		{"{ATTR::regions[1].basins[0]}", map[string]string{"p": "c"}},
+		{"{ATTR::regions[].name}/grid.tif", map[string]string{"p-north": "north/grid.tif", "p-south": "south/grid.tif"}},
+		{"{ATTR::regions[].basins[]}", map[string]string{"p-a": "a", "p-b": "b", "p-c": "c"}},
+		{"{ATTR::colors.bold.red9:-red}", map[string]string{"p": "red"}},
+	}
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			results, err := parameterSubstitute(paramSubInput{
+				TemplateKey:                "p",
+				Template:                   test.template,
+				Attributes:                 attrs,
+				AllowAttributeSubstitution: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(results, test.expected) {
+				t.Fatalf("expected %v, found %v", test.expected, results)
+			}
+		})
+	}
+
+	_, err = parameterSubstitute(paramSubInput{
+		TemplateKey:                "p",
+		Template:                   "{ATTR::colors.bold.red9}",
+		Attributes:                 attrs,
+		AllowAttributeSubstitution: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), `key "red9" not found`) {
+		t.Fatalf("expected a missing key error, found %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package cc
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultResourceMonitorInterval is the time between resource usage samples of a running action
+const DefaultResourceMonitorInterval = 30 * time.Second
+
+// defaultClockTicks is the USER_HZ of common linux builds, used when the auxiliary vector can not be read
+const defaultClockTicks = 100
+
+// atClockTicks is the AT_CLKTCK auxiliary vector entry: the value of sysconf(_SC_CLK_TCK)
+const atClockTicks = 17
+
+// procClockTicks returns the clock ticks per second of the cpu times in /proc/<pid>/stat
+var procClockTicks = sync.OnceValue(func() float64 {
+	data, err := os.ReadFile("/proc/self/auxv")
+	if err != nil {
+		return defaultClockTicks
+	}
+	//the auxiliary vector is a list of native word sized type and value pairs
+	word := strconv.IntSize / 8
+	for i := 0; i+2*word <= len(data); i += 2 * word {
+		if auxvWord(data[i:i+word]) == atClockTicks {
+			if ticks := auxvWord(data[i+word : i+2*word]); ticks > 0 {
+				return float64(ticks)
+			}
+		}
+	}
+	return defaultClockTicks
+})
+
+func auxvWord(b []byte) uint64 {
+	if len(b) == 4 {
+		return uint64(binary.NativeEndian.Uint32(b))
+	}
+	return binary.NativeEndian.Uint64(b)
+}
+
+// ResourceUsage is the resource usage of an action, sampled while it runs.
+//
+// Memory and cpu are measured for the plugin process and its child processes (for example the
+// executables started with RunCommand).  When actions run in parallel the process measurements
+// include every running action.  Usage is read from /proc and is only reported on Linux.
+//
+// PeakFilesystemUsedBytes is the used space of the whole file system that holds the CcStore root
+// path, including data written by other processes, not the size of the root path directory.
+type ResourceUsage struct {
+	Samples                 int     `json:"samples"`
+	PeakRSSBytes            uint64  `json:"peak_rss_bytes"`
+	CPUSeconds              float64 `json:"cpu_seconds"`
+	PeakWorkspaceBytes      uint64  `json:"peak_workspace_bytes"`
+	PeakFilesystemUsedBytes uint64  `json:"peak_filesystem_used_bytes"`
+}
+
+type resourceSample struct {
+	rss       uint64
+	cpu       float64
+	workspace uint64
+	fsUsed    uint64
+}
+
+// resourceMonitor samples resource usage on an interval until it is stopped
+type resourceMonitor struct {
+	pm       *PluginManager
+	action   string
+	ws       *actionWorkspace
+	dataRoot string
+	stop     chan struct{}
+	done     chan struct{}
+	mu       sync.Mutex
+	startCPU float64
+	usage    ResourceUsage
+}
+
+// startResourceMonitor starts sampling the resource usage of an action.
+// It returns nil when monitoring is disabled or /proc is not available.
+func (pm *PluginManager) startResourceMonitor(action Action, ws *actionWorkspace) *resourceMonitor {
+	if pm.config.DisableResourceMonitor {
+		return nil
+	}
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		return nil
+	}
+	interval := pm.config.ResourceMonitorInterval
+	if interval <= 0 {
+		interval = DefaultResourceMonitorInterval
+	}
+	dataRoot := localRootPath
+	if pm.ccStore != nil {
+		dataRoot = pm.ccStore.RootPath()
+	}
+	rm := &resourceMonitor{
+		pm:       pm,
+		action:   action.Name,
+		ws:       ws,
+		dataRoot: dataRoot,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	rm.startCPU = rm.sample().cpu
+	go rm.run(interval)
+	return rm
+}
+
+func (rm *resourceMonitor) run(interval time.Duration) {
+	defer close(rm.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s := rm.record()
+			rm.pm.Logger.Info("resource usage",
+				"action", rm.action,
+				"rss_bytes", s.rss,
+				"cpu_seconds", s.cpu-rm.startCPU,
+				"workspace_bytes", s.workspace,
+				"filesystem_used_bytes", s.fsUsed,
+			)
+		case <-rm.stop:
+			return
+		}
+	}
+}
+
+// finish stops sampling, logs the peak usage and returns it
+func (rm *resourceMonitor) finish() *ResourceUsage {
+	if rm == nil {
+		return nil
+	}
+	close(rm.stop)
+	<-rm.done
+	rm.record()
+	rm.mu.Lock()
+	usage := rm.usage
+	rm.mu.Unlock()
+	rm.pm.Logger.Info("resource usage peak",
+		"action", rm.action,
+		"samples", usage.Samples,
+		"peak_rss_bytes", usage.PeakRSSBytes,
+		"cpu_seconds", usage.CPUSeconds,
+		"peak_workspace_bytes", usage.PeakWorkspaceBytes,
+		"peak_filesystem_used_bytes", usage.PeakFilesystemUsedBytes,
+	)
+	return &usage
+}
+
+// record takes a sample and updates the peak usage
+func (rm *resourceMonitor) record() resourceSample {
+	s := rm.sample()
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.usage.Samples++
+	rm.usage.PeakRSSBytes = max(rm.usage.PeakRSSBytes, s.rss)
+	rm.usage.CPUSeconds = max(rm.usage.CPUSeconds, s.cpu-rm.startCPU)
+	rm.usage.PeakWorkspaceBytes = max(rm.usage.PeakWorkspaceBytes, s.workspace)
+	rm.usage.PeakFilesystemUsedBytes = max(rm.usage.PeakFilesystemUsedBytes, s.fsUsed)
+	return s
+}
+
+func (rm *resourceMonitor) sample() resourceSample {
+	s := resourceSample{}
+	s.rss, s.cpu = processTreeUsage(os.Getpid())
+	if rm.ws != nil {
+		s.workspace = directorySize(rm.ws.current())
+	}
+	if used, ok, err := usedSpace(rm.dataRoot); err == nil && ok {
+		s.fsUsed = used
+	}
+	return s
+}
+
+type procStat struct {
+	ppid     int
+	rssPages uint64
+	cpuTicks uint64 //user and system time of the process and its waited for children
+}
+
+// processTreeUsage returns the resident memory in bytes and the cpu time in seconds
+// of a process and all of its descendants
+func processTreeUsage(pid int) (uint64, float64) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, 0
+	}
+	stats := map[int]procStat{}
+	children := map[int][]int{}
+	for _, entry := range entries {
+		p, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := readProcStat(p)
+		if err != nil {
+			continue //the process exited
+		}
+		stats[p] = stat
+		children[stat.ppid] = append(children[stat.ppid], p)
+	}
+	var rssPages, ticks uint64
+	pending := []int{pid}
+	for len(pending) > 0 {
+		p := pending[0]
+		pending = pending[1:]
+		if stat, ok := stats[p]; ok {
+			rssPages += stat.rssPages
+			ticks += stat.cpuTicks
+		}
+		pending = append(pending, children[p]...)
+	}
+	return rssPages * uint64(os.Getpagesize()), float64(ticks) / procClockTicks()
+}
+
+// readProcStat parses /proc/<pid>/stat.  See proc(5) for the field layout.
+func readProcStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+	//the command name can contain spaces so the fields are read after its closing parenthesis
+	content := string(data)
+	fields := strings.Fields(content[strings.LastIndexByte(content, ')')+1:])
+	if len(fields) < 22 {
+		return procStat{}, fs.ErrInvalid
+	}
+	field := func(n int) uint64 {
+		v, _ := strconv.ParseUint(fields[n-3], 10, 64) //fields are numbered from 1 and start at field 3
+		return v
+	}
+	return procStat{
+		ppid:     int(field(4)),
+		rssPages: field(24),
+		cpuTicks: field(14) + field(15) + field(16) + field(17),
+	}, nil
+}
+
+// directorySize returns the total size of the files under dir
+func directorySize(dir string) uint64 {
+	if dir == "" {
+		return 0
+	}
+	var size uint64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil //files can be removed while the action runs
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += uint64(info.Size())
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,55 @@
+package cc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type resourceTestAction struct {
+	ActionRunnerBase
+}
+
+func (a *resourceTestAction) Run() error {
+	dir, err := a.Workspace()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "grid.bin"), make([]byte, 1<<20), 0644); err != nil {
+		return err
+	}
+	time.Sleep(50 * time.Millisecond)
+	return nil
+}
+
+func TestResourceMonitor(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("resource monitoring requires /proc")
+	}
+	RegisterActionType[resourceTestAction]("resource-action")
+	pm := newTestPluginManager(Action{Name: "resource-action"})
+	pm.ccStore = &FSBCcStore{localRootPath: t.TempDir(), remoteRootPath: t.TempDir()}
+	pm.config.ResourceMonitorInterval = 10 * time.Millisecond
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	usage := pm.RunSummary().Actions[0].Resources
+	if usage == nil {
+		t.Fatal("expected the resource usage in the action result")
+	}
+	if usage.Samples < 2 || usage.PeakRSSBytes == 0 || usage.PeakFilesystemUsedBytes == 0 {
+		t.Fatalf("expected process and file system samples: %+v", usage)
+	}
+	if usage.PeakWorkspaceBytes < 1<<20 {
+		t.Fatalf("expected the workspace usage to include the 1MB grid: %+v", usage)
+	}
+
+	rss, cpu := processTreeUsage(os.Getpid())
+	if rss == 0 || cpu < 0 {
+		t.Fatalf("unexpected process usage %d %f", rss, cpu)
+	}
+	if ticks := procClockTicks(); ticks <= 0 {
+		t.Fatalf("unexpected clock ticks %f", ticks)
+	}
+}
@@ -0,0 +1,160 @@
+package cc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ExitCodeInterrupted is the process exit code used when the plugin shuts down after SIGINT or SIGTERM.
+// It is the sysexits EX_TEMPFAIL code and tells the orchestrator the event can be requeued.
+const ExitCodeInterrupted = 75
+
+// DefaultShutdownGracePeriod is the time in-flight store writes are given to finish after an interrupt signal
+const DefaultShutdownGracePeriod = 20 * time.Second
+
+// ErrShuttingDown is returned by store writes started after the plugin received an interrupt signal
+var ErrShuttingDown = errors.New("plugin is shutting down: no new store writes are accepted")
+
+// exitFunc is replaced in tests
+var exitFunc = os.Exit
+
+// writeTracker counts in-flight store writes so shutdown can wait for them to finish.
+// Each PluginManager has its own tracker.  Only the IOManager Put, Copy and CopyFileToRemote
+// writes are tracked; reads (GetObject, PullObject, Get) and writes made directly on a store
+// session are not.
+type writeTracker struct {
+	mu       sync.Mutex
+	active   int
+	draining bool
+	deadline time.Time
+	idle     chan struct{}
+}
+
+// begin registers a new write.  Writes are rejected once the tracker is draining.
+// A nil tracker accepts every write.
+func (wt *writeTracker) begin() error {
+	if wt == nil {
+		return nil
+	}
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	if wt.draining {
+		return ErrShuttingDown
+	}
+	wt.active++
+	return nil
+}
+
+func (wt *writeTracker) end() {
+	if wt == nil {
+		return
+	}
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	wt.active--
+	if wt.active == 0 && wt.idle != nil {
+		close(wt.idle)
+		wt.idle = nil
+	}
+}
+
+// stop rejects new writes and starts the grace period of the active writes.
+// Stopping a tracker that is already draining keeps the original grace period.
+func (wt *writeTracker) stop(grace time.Duration) {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	wt.stopLocked(grace)
+}
+
+func (wt *writeTracker) stopLocked(grace time.Duration) {
+	if !wt.draining {
+		wt.draining = true
+		wt.deadline = time.Now().Add(grace)
+	}
+}
+
+// drain stops the tracker and waits until the end of the grace period for the active writes to finish.
+// It returns the number of writes that did not finish.
+func (wt *writeTracker) drain(grace time.Duration) int {
+	wt.mu.Lock()
+	wt.stopLocked(grace)
+	if wt.active == 0 {
+		wt.mu.Unlock()
+		return 0
+	}
+	if wt.idle == nil {
+		wt.idle = make(chan struct{})
+	}
+	idle := wt.idle
+	deadline := wt.deadline
+	wt.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return 0
+	case <-timer.C:
+		wt.mu.Lock()
+		defer wt.mu.Unlock()
+		return wt.active
+	}
+}
+
+// resume accepts writes again after an interrupted run that did not exit
+func (wt *writeTracker) resume() {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	wt.draining = false
+	wt.deadline = time.Time{}
+}
+
+// interrupted reports whether ctx was cancelled by an interrupt signal
+func interrupted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrInterruptSignal)
+}
+
+// shutdownGracePeriod is the configured ShutdownGracePeriod or DefaultShutdownGracePeriod
+func (pm *PluginManager) shutdownGracePeriod() time.Duration {
+	if pm.config.ShutdownGracePeriod <= 0 {
+		return DefaultShutdownGracePeriod
+	}
+	return pm.config.ShutdownGracePeriod
+}
+
+// stopStoreWrites rejects new store writes and starts the shutdown grace period as soon as an interrupt
+// signal is received, while the interrupted actions are still returning
+func (pm *PluginManager) stopStoreWrites() {
+	grace := pm.shutdownGracePeriod()
+	pm.Logger.Warn("interrupt received: waiting for in-flight store writes", "grace_period", grace.String())
+	pm.writes.stop(grace)
+}
+
+// drainStoreWrites waits for the in-flight store writes of the interrupted actions until the end of
+// the grace period started by stopStoreWrites
+func (pm *PluginManager) drainStoreWrites() {
+	if remaining := pm.writes.drain(pm.shutdownGracePeriod()); remaining > 0 {
+		pm.Logger.Error("store writes did not finish within the grace period", "writes", remaining)
+	}
+}
+
+// trackWrites shares the manager write tracker with the payload and action IOManagers
+func (pm *PluginManager) trackWrites() {
+	pm.IOManager.writes = &pm.writes
+	for i := range pm.Actions {
+		pm.Actions[i].IOManager.writes = &pm.writes
+	}
+}
+
+// exitInterrupted exits the process after an interrupt signal so the orchestrator can requeue the event.
+// A manager created with WithReturnOnInterrupt accepts writes again and returns to the caller instead.
+func (pm *PluginManager) exitInterrupted() {
+	pm.Logger.Error("plugin interrupted", "event", pm.EventIdentifier, "exit_code", ExitCodeInterrupted)
+	if !pm.returnOnInterrupt {
+		exitFunc(ExitCodeInterrupted)
+	}
+	pm.writes.resume()
+}
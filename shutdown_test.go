@@ -0,0 +1,128 @@
+package cc
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// slowWriter is a StoreWriter session that signals the process in the middle of a write
+type slowWriter struct {
+	started  chan struct{}
+	finished atomic.Bool
+}
+
+func (w *slowWriter) Put(reader io.Reader, path string, datapath string) (int, error) {
+	close(w.started)
+	time.Sleep(100 * time.Millisecond)
+	w.finished.Store(true)
+	return 0, nil
+}
+
+type uploadTestAction struct {
+	ActionRunnerBase
+	writer *slowWriter
+}
+
+func (a *uploadTestAction) Run() error {
+	go func() {
+		<-a.writer.started
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+	_, err := a.Action.Put(PutOpInput{SrcReader: strings.NewReader("results"), DataSourceOpInput: DataSourceOpInput{DataSourceName: "results", PathKey: "default"}})
+	return err
+}
+
+func TestRunActionsGracefulShutdown(t *testing.T) {
+	exitCode := -1
+	exitFunc = func(code int) {
+		exitCode = code
+	}
+	defer func() { exitFunc = os.Exit }()
+
+	for _, returnOnInterrupt := range []bool{false, true} {
+		writer := &slowWriter{started: make(chan struct{})}
+		RegisterActionFactory("upload-action", func(pm *PluginManager, action Action) (ActionRunner, error) {
+			return &uploadTestAction{ActionRunnerBase{ActionName: action.Name, PluginManager: pm, Action: action}, writer}, nil
+		})
+		exitCode = -1
+
+		pm := newTestPluginManager(Action{
+			Name: "upload-action",
+			IOManager: IOManager{
+				Stores:  []DataStore{{Name: "remote", Session: writer}},
+				Outputs: []DataSource{{Name: "results", StoreName: "remote", Paths: map[string]string{"default": "results.txt"}}},
+			},
+		})
+		pm.config.ShutdownGracePeriod = 5 * time.Second
+		pm.returnOnInterrupt = returnOnInterrupt
+		err := pm.RunActions()
+		if !errors.Is(err, ErrInterruptSignal) {
+			t.Fatalf("expected an interrupt error, found %v", err)
+		}
+		if !writer.finished.Load() {
+			t.Fatal("expected the in-flight write to finish before shutdown")
+		}
+		expectedCode := ExitCodeInterrupted
+		if returnOnInterrupt {
+			expectedCode = -1
+		}
+		if exitCode != expectedCode {
+			t.Fatalf("expected exit code %d with return on interrupt %t, found %d", expectedCode, returnOnInterrupt, exitCode)
+		}
+		if status := pm.RunSummary().Status; status != INTERRUPTED {
+			t.Fatalf("expected an interrupted run summary, found %s", status)
+		}
+		if err := pm.writes.begin(); err != nil {
+			t.Fatalf("expected the manager to accept writes after the interrupted run, found %v", err)
+		}
+	}
+}
+
+func TestWriteTrackerDrain(t *testing.T) {
+	wt := &writeTracker{}
+	if err := wt.begin(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		wt.end()
+	}()
+	if remaining := wt.drain(time.Second); remaining != 0 {
+		t.Fatalf("expected the write to finish, %d remaining", remaining)
+	}
+	if err := wt.begin(); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("expected writes to be rejected while draining, found %v", err)
+	}
+	wt.resume()
+	if err := wt.begin(); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := wt.drain(10 * time.Millisecond); remaining != 1 {
+		t.Fatalf("expected one unfinished write, found %d", remaining)
+	}
+}
+
+func TestWriteTrackerStop(t *testing.T) {
+	wt := &writeTracker{}
+	if err := wt.begin(); err != nil {
+		t.Fatal(err)
+	}
+	wt.stop(20 * time.Millisecond)
+	if err := wt.begin(); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("expected writes to be rejected after stop, found %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	start := time.Now()
+	if remaining := wt.drain(time.Second); remaining != 1 {
+		t.Fatalf("expected one unfinished write, found %d", remaining)
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Fatalf("expected drain to use the grace period started by stop, waited %s", waited)
+	}
+}
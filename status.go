@@ -6,6 +6,9 @@ const (
 	COMPUTING Status = "Computing"
 	FAILED    Status = "Failed"
 	SUCCEEDED Status = "Succeeded"
+
+	//the plugin received SIGINT or SIGTERM before the actions completed
+	INTERRUPTED Status = "Interrupted"
 )
 
 type StatusReport struct {
@@ -0,0 +1,147 @@
+package cc
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// SubstitutionFilter is a formatting filter applied to a substituted value,
+// i.e. {CC::EVENT_NUMBER|pad:4} or {ATTR::plan|upper}.  Filters are applied in order.
+type SubstitutionFilter struct {
+	Name string
+	Arg  string
+}
+
+const (
+	filterPad      = "pad"      //left pads the value with zeros to the argument width
+	filterUpper    = "upper"    //upper cases the value
+	filterLower    = "lower"    //lower cases the value
+	filterPrintf   = "printf"   //formats the value with the argument as a fmt format string
+	filterBasename = "basename" //last element of a slash separated path
+	filterDefault  = "default"  //replaces an empty or unset value with the argument
+)
+
+type substitutionFilterFunc func(v any, arg string) (any, error)
+
+var substitutionFilters = map[string]substitutionFilterFunc{
+	filterPad: padFilter,
+	filterUpper: func(v any, _ string) (any, error) {
+		return strings.ToUpper(substitutionString(v)), nil
+	},
+	filterLower: func(v any, _ string) (any, error) {
+		return strings.ToLower(substitutionString(v)), nil
+	},
+	filterPrintf: printfFilter,
+	filterBasename: func(v any, _ string) (any, error) {
+		return path.Base(substitutionString(v)), nil
+	},
+	filterDefault: func(v any, arg string) (any, error) {
+		if substitutionString(v) == "" {
+			return arg, nil
+		}
+		return v, nil
+	},
+}
+
+func parseSubstitutionFilters(filters string) []SubstitutionFilter {
+	if filters == "" {
+		return nil
+	}
+	parsed := []SubstitutionFilter{}
+	for _, f := range strings.Split(strings.TrimPrefix(filters, "|"), "|") {
+		name, arg, _ := strings.Cut(f, ":")
+		parsed = append(parsed, SubstitutionFilter{name, arg})
+	}
+	return parsed
+}
+
+func (ev EmbeddedVar) hasFilter(name string) bool {
+	for _, f := range ev.Filters {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// filterValue applies the filters to a substituted value and formats the result.
+// The filters are applied to each element of a slice before it is concatenated into a csv string.
+func filterValue(v any, filters []SubstitutionFilter) (string, error) {
+	vof := reflect.ValueOf(v)
+	if vof.Kind() == reflect.Slice || vof.Kind() == reflect.Array {
+		elements := make([]string, vof.Len())
+		for i := range elements {
+			element, err := filterValue(vof.Index(i).Interface(), filters)
+			if err != nil {
+				return "", err
+			}
+			elements[i] = element
+		}
+		return strings.Join(elements, ","), nil
+	}
+	for _, f := range filters {
+		filter, ok := substitutionFilters[f.Name]
+		if !ok {
+			return "", fmt.Errorf("unknown substitution filter: %s", f.Name)
+		}
+		var err error
+		v, err = filter(v, f.Arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s filter: %w", f.Name, err)
+		}
+	}
+	return substitutionString(v), nil
+}
+
+func padFilter(v any, arg string) (any, error) {
+	width, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid width %q", arg)
+	}
+	str := substitutionString(v)
+	sign := ""
+	if strings.HasPrefix(str, "-") {
+		sign, str = "-", str[1:]
+	}
+	if pad := width - len(sign) - len(str); pad > 0 {
+		str = strings.Repeat("0", pad) + str
+	}
+	return sign + str, nil
+}
+
+// printfFilter formats v with a single verb format string.
+// numeric strings (i.e. ENV and CC values) and JSON numbers are converted to match integer and float verbs.
+func printfFilter(v any, format string) (any, error) {
+	if format == "" {
+		return nil, fmt.Errorf("missing format")
+	}
+	arg := v
+	//parse strings as base 10 so zero padded values are not read as octal
+	if str, ok := v.(string); ok {
+		if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+			v = i
+		} else if f, err := strconv.ParseFloat(str, 64); err == nil {
+			v = f
+		}
+	}
+	switch format[len(format)-1] {
+	case 'd', 'b', 'o', 'x', 'X', 'c':
+		i, err := cast.ToInt64E(v)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not an integer", arg)
+		}
+		arg = i
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		f, err := cast.ToFloat64E(v)
+		if err != nil {
+			return nil, fmt.Errorf("%v is not a number", arg)
+		}
+		arg = f
+	}
+	return fmt.Sprintf(format, arg), nil
+}
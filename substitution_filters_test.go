@@ -0,0 +1,65 @@
+package cc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubstitutionFilters(t *testing.T) {
+	input := paramSubInput{
+		TemplateKey: "p",
+		Attributes: map[string]any{
+			"plan":       "p04",
+			"cell":       2.5,
+			"path":       "models/muncie/terrain.tif",
+			"events":     []any{7, 12},
+			"empty":      "",
+			"Plan_Title": "Muncie Plan",
+		},
+		AllowAttributeSubstitution: true,
+		LookupEnv: func(key string) (string, bool) {
+			if key == "EVENTS" {
+				return "3,011", true
+			}
+			return "", false
+		},
+		CcVars: map[string]string{CcVarEventNumber: "7"},
+	}
+	tests := []struct {
+		template string
+		expected map[string]string
+	}{
+		{"event_{CC::EVENT_NUMBER|pad:4}", map[string]string{"p": "event_0007"}},
+		{"{ATTR::plan|upper}", map[string]string{"p": "P04"}},
+		{"{ATTR::Plan_Title|lower}", map[string]string{"p": "muncie plan"}},
+		{"{ATTR::cell|printf:%05.1f}", map[string]string{"p": "002.5"}},
+		{"{CC::EVENT_NUMBER|printf:%03d}", map[string]string{"p": "007"}},
+		{"{ATTR::path|basename}", map[string]string{"p": "terrain.tif"}},
+		{"{ATTR::empty|default:foo}", map[string]string{"p": "foo"}},
+		{"{ATTR::missing|default:7|pad:3}", map[string]string{"p": "007"}},
+		{"{ATTR::plan|default:foo|upper}", map[string]string{"p": "P04"}},
+		{"{ENV::EVENTS|pad:3}", map[string]string{"p": "003,011"}},
+		{"event_{ATTR::events[]|pad:4}", map[string]string{"p-0007": "event_0007", "p-0012": "event_0012"}},
+		{"{ENV::EVENTS[]|printf:e%d}", map[string]string{"p-e3": "e3", "p-e11": "e11"}},
+		{"{ENV::REGION:-east|upper}", map[string]string{"p": "EAST"}},
+	}
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			input.Template = test.template
+			results, err := parameterSubstitute(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(results, test.expected) {
+				t.Fatalf("expected %v, found %v", test.expected, results)
+			}
+		})
+	}
+
+	for _, template := range []string{"{ATTR::plan|shout}", "{ATTR::plan|pad:x}", "{ATTR::plan|printf:%d}"} {
+		input.Template = template
+		if _, err := parameterSubstitute(input); err == nil {
+			t.Fatalf("expected %s to fail", template)
+		}
+	}
+}
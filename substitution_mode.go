@@ -0,0 +1,138 @@
+package cc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// CcSubstitutionMode is the environment variable that selects the substitution mode
+// when PluginManagerConfig.SubstitutionMode is not set
+const CcSubstitutionMode = "CC_SUBSTITUTION_MODE"
+
+// SubstitutionMode selects how payload templates that can not be resolved are handled
+type SubstitutionMode string
+
+const (
+	//unresolved attribute and store parameter templates are left in place.
+	//unresolved data source templates and required references fail initialization
+	SubstitutionLenient SubstitutionMode = "lenient"
+
+	//every unresolved or invalid reference fails initialization with a single report
+	SubstitutionStrict SubstitutionMode = "strict"
+
+	//unresolved or invalid references are logged as warnings and handled the same as lenient mode
+	SubstitutionReport SubstitutionMode = "report"
+)
+
+// referenceRegex matches anything that looks like a substitution reference, valid or not
+var referenceRegex = regexp.MustCompile(`\{[A-Za-z_]+::[^{}]*\}`)
+
+// SubstitutionReportError lists every template that could not be substituted
+type SubstitutionReportError struct {
+	Errors []*SubstitutionError
+}
+
+func (sre *SubstitutionReportError) Error() string {
+	if len(sre.Errors) == 1 {
+		return sre.Errors[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "substitution failed with %d errors:", len(sre.Errors))
+	for _, se := range sre.Errors {
+		fmt.Fprintf(&b, "\n  %s in %q: %s", se.Location, se.Template, se.Err)
+	}
+	return b.String()
+}
+
+func (sre *SubstitutionReportError) Unwrap() []error {
+	errs := make([]error, len(sre.Errors))
+	for i, se := range sre.Errors {
+		errs[i] = se
+	}
+	return errs
+}
+
+// substitutionMode returns the configured substitution mode, defaulting to lenient
+func (pm *PluginManager) substitutionMode() (SubstitutionMode, error) {
+	mode := pm.config.SubstitutionMode
+	if mode == "" {
+		mode = SubstitutionMode(strings.ToLower(pm.getenv(CcSubstitutionMode)))
+	}
+	switch mode {
+	case "":
+		return SubstitutionLenient, nil
+	case SubstitutionLenient, SubstitutionStrict, SubstitutionReport:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid substitution mode: %s", mode)
+}
+
+// substitutionCollector gathers the templates that could not be substituted while walking a payload
+type substitutionCollector struct {
+	mode     SubstitutionMode
+	errs     []*SubstitutionError
+	warnings []*SubstitutionError
+}
+
+// unresolved records a template that could not be substituted.
+// fatal failures and required references fail initialization in every mode.
+// The remaining failures fail in strict mode, are logged in report mode and are ignored in lenient mode.
+func (c *substitutionCollector) unresolved(location string, template string, err error, fatal bool) {
+	se := &SubstitutionError{location, template, err}
+	switch {
+	case fatal || errors.Is(err, ErrRequiredSubstitution) || c.mode == SubstitutionStrict:
+		c.errs = append(c.errs, se)
+	case c.mode == SubstitutionReport:
+		c.warnings = append(c.warnings, se)
+	}
+}
+
+// check records the references in a substituted template that will never resolve to a value:
+// malformed references, attribute references where attribute substitution is not allowed
+// and ENV or CC references without a default that are unset or empty.
+func (c *substitutionCollector) check(location string, input paramSubInput) {
+	if c.mode == SubstitutionLenient {
+		return
+	}
+	template := input.Template
+	for _, ref := range referenceRegex.FindAllString(template, -1) {
+		match := substitutionRegex.FindStringSubmatch(ref)
+		if match == nil || match[0] != ref {
+			c.unresolved(location, template, fmt.Errorf("invalid reference %s", ref), false)
+			continue
+		}
+		ev := matchToEmbeddedVars(match)
+		if ev.HasDefault || ev.Required || ev.hasFilter(filterDefault) {
+			continue
+		}
+		switch ev.Type {
+		case ParamSubAttr:
+			if !input.AllowAttributeSubstitution {
+				c.unresolved(location, template, fmt.Errorf("attribute reference %s is not substituted in payload attributes", ref), false)
+			}
+		case ParmamSubEnv:
+			lookupEnv := input.LookupEnv
+			if lookupEnv == nil {
+				lookupEnv = os.LookupEnv
+			}
+			if val, _ := lookupEnv(ev.Varname); val == "" {
+				c.unresolved(location, template, fmt.Errorf("environment variable %s is not set", ev.Varname), false)
+			}
+		case ParamSubCc:
+			if input.CcVars[ev.Varname] == "" {
+				c.unresolved(location, template, fmt.Errorf("CC variable %s is not set", ev.Varname), false)
+			}
+		}
+	}
+}
+
+// err returns a SubstitutionReportError or nil when every template was substituted
+func (c *substitutionCollector) err() error {
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return &SubstitutionReportError{c.errs}
+}
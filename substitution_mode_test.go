@@ -0,0 +1,150 @@
+package cc
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func substitutionModeTestPayload(badPath bool) Payload {
+	gridPath := "{ATTR::grid_name}.tif"
+	if badPath {
+		gridPath = "{ATTR::grd}.tif"
+	}
+	return Payload{
+		IOManager: IOManager{
+			Attributes: PayloadAttributes{
+				"grid_name": "grid",
+				"ref":       "{ATTR::grid_name}",
+				"bucket":    "{ENV::NOPE_BUCKET}",
+				"run":       "{CC::EVENT_ID}",
+				"frames":    []any{9, 10, 2},
+			},
+			Stores: []DataStore{{Name: "local", StoreType: "TEST", Parameters: PayloadAttributes{"root": "/data"}}},
+		},
+		Actions: []Action{{
+			Name: "compute",
+			IOManager: IOManager{
+				Attributes: PayloadAttributes{
+					"cell":   "{ATTR::cell_size}",
+					"typo":   "{ATR::grid_name}",
+					"files":  []any{"{ATTR::grid_name}.hdf", "{ATTR::missing}.hdf"},
+					"frames": []any{"f{ATTR::frames[]}"},
+				},
+				Stores: []DataStore{{Name: "scratch", StoreType: "TEST", Parameters: PayloadAttributes{"root": "/{ATTR::missing}"}}},
+				Inputs: []DataSource{{
+					Name:      "terrain",
+					StoreName: "local",
+					Paths:     map[string]string{"grid": gridPath},
+					DataPaths: map[string]string{"grid": "{ATTR::grid_name}/elevation"},
+				}},
+			},
+		}},
+	}
+}
+
+func TestSubstitutionModes(t *testing.T) {
+	registry := DataStoreTypeRegistryMap{}
+	registry.Register("TEST", struct{}{})
+
+	t.Run("lenient", func(t *testing.T) {
+		pm, err := initTestPluginManager(t, WithPayload(substitutionModeTestPayload(false)), WithStoreRegistry(registry))
+		if err != nil {
+			t.Fatal(err)
+		}
+		action := pm.Actions[0]
+		results := map[string]any{
+			"ref":        pm.Attributes["ref"],
+			"cell":       action.Attributes["cell"],
+			"files":      action.Attributes["files"],
+			"frames":     action.Attributes["frames"],
+			"bucket":     pm.Attributes["bucket"],
+			"paths":      action.Inputs[0].Paths,
+			"data paths": action.Inputs[0].DataPaths,
+		}
+		expected := map[string]any{
+			"ref":        "{ATTR::grid_name}",
+			"cell":       "{ATTR::cell_size}",
+			"files":      []any{"grid.hdf", "{ATTR::missing}.hdf"},
+			"frames":     []any{"f9", "f10", "f2"},
+			"bucket":     "",
+			"paths":      map[string]string{"grid": "grid.tif"},
+			"data paths": map[string]string{"grid": "grid/elevation"},
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Fatalf("expected %v, found %v", expected, results)
+		}
+
+		_, err = initTestPluginManager(t, WithPayload(substitutionModeTestPayload(true)), WithStoreRegistry(registry))
+		var serr *SubstitutionError
+		if !errors.As(err, &serr) || serr.Location != "$.actions[0].inputs[0].paths.grid" {
+			t.Fatalf("expected the unresolved path to fail, found %v", err)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := initTestPluginManager(t, WithPayload(substitutionModeTestPayload(true)), WithStoreRegistry(registry),
+			WithConfig(PluginManagerConfig{SubstitutionMode: SubstitutionStrict}))
+		var report *SubstitutionReportError
+		if !errors.As(err, &report) {
+			t.Fatalf("expected a substitution report, found %v", err)
+		}
+		locations := []string{}
+		for _, se := range report.Errors {
+			locations = append(locations, se.Location)
+		}
+		slices.Sort(locations)
+		expected := []string{
+			"$.actions[0].attributes.cell",
+			"$.actions[0].attributes.files[1]",
+			"$.actions[0].attributes.typo",
+			"$.actions[0].inputs[0].paths.grid",
+			"$.actions[0].stores[0].params.root",
+			"$.attributes.bucket",
+			"$.attributes.ref",
+			"$.attributes.run",
+		}
+		if !reflect.DeepEqual(locations, expected) {
+			t.Fatalf("expected errors at %v, found %v", expected, locations)
+		}
+		if !strings.Contains(err.Error(), "substitution failed with 8 errors") {
+			t.Fatalf("expected a single report of every error, found %v", err)
+		}
+	})
+
+	t.Run("report", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		_, err := initTestPluginManager(t, WithPayload(substitutionModeTestPayload(false)), WithStoreRegistry(registry),
+			WithEnv(map[string]string{CcSubstitutionMode: "report"}),
+			WithLogger(&CcLogger{Logger: slog.New(slog.NewJSONHandler(buf, nil))}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs := buf.String()
+		for _, location := range []string{
+			"$.attributes.ref",
+			"$.attributes.bucket",
+			"$.attributes.run",
+			"$.actions[0].attributes.cell",
+			"$.actions[0].attributes.typo",
+			"$.actions[0].attributes.files[1]",
+			"$.actions[0].stores[0].params.root",
+		} {
+			if !strings.Contains(logs, `"msg":"unresolved substitution","location":"`+location+`"`) {
+				t.Fatalf("expected a warning for %s:\n%s", location, logs)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := initTestPluginManager(t, WithPayload(substitutionModeTestPayload(false)), WithStoreRegistry(registry),
+			WithConfig(PluginManagerConfig{SubstitutionMode: "loose"}))
+		if err == nil || !strings.Contains(err.Error(), "invalid substitution mode: loose") {
+			t.Fatalf("expected an invalid mode error, found %v", err)
+		}
+	})
+}
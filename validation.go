@@ -0,0 +1,54 @@
+package cc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Violation is a single validation failure located by a JSON path such as $.actions[0].attributes.cell_size
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// ValidationError reports every violation found while validating a payload element
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (ve *ValidationError) Error() string {
+	if len(ve.Violations) == 1 {
+		return "validation failed: " + ve.Violations[0].String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "validation failed with %d errors:", len(ve.Violations))
+	for _, v := range ve.Violations {
+		b.WriteString("\n  ")
+		b.WriteString(v.String())
+	}
+	return b.String()
+}
+
+func (ve *ValidationError) add(path string, format string, args ...any) {
+	ve.Violations = append(ve.Violations, Violation{path, fmt.Sprintf(format, args...)})
+}
+
+// err returns the ValidationError or nil when there are no violations
+func (ve *ValidationError) err() error {
+	if len(ve.Violations) == 0 {
+		return nil
+	}
+	return ve
+}
+
+func jsonPathIndex(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
+
+func jsonPathField(path string, field string) string {
+	return path + "." + field
+}
@@ -0,0 +1,176 @@
+package cc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const workspaceDir = "workspaces"
+
+// ErrInsufficientSpace is returned when the file system of a workspace has less free space than required
+var ErrInsufficientSpace = errors.New("insufficient free disk space")
+
+// WorkspaceConfig controls the scratch workspaces the PluginManager creates for actions.
+// Workspaces are created under CcStore.RootPath()/workspaces/<event identifier> and are
+// removed when the action completes unless the config keeps them.  The event directory is
+// removed after the event when it no longer holds a workspace.
+type WorkspaceConfig struct {
+	//keep the workspace of a failed action for debugging.  the path is reported in the action result
+	KeepOnFailure bool
+
+	//keep the workspace of a successful action.  by default it is removed
+	KeepOnSuccess bool
+
+	//minimum free space in bytes required on the workspace file system before the workspace is created.
+	//zero disables the check.  the check is skipped on platforms that can not report free space
+	MinFreeBytes uint64
+}
+
+// actionWorkspace is the scratch directory of a single action run.
+// The directory is created on first use so actions that do not need a workspace do not create one.
+type actionWorkspace struct {
+	root         string
+	action       string
+	minFreeBytes uint64
+	mu           sync.Mutex
+	dir          string
+}
+
+type workspaceSetter interface {
+	setWorkspace(ws *actionWorkspace)
+}
+
+// Workspace returns a scratch directory that is unique to the running action.
+// The directory is created under the CcStore root path on the first call and is
+// removed by the PluginManager when the action completes (see WorkspaceConfig).
+func (arb *ActionRunnerBase) Workspace() (string, error) {
+	if arb.workspace == nil {
+		return "", fmt.Errorf("action %s does not have a workspace. workspaces are only available while the PluginManager runs the action", arb.ActionName)
+	}
+	return arb.workspace.path()
+}
+
+// EnsureFreeSpace returns ErrInsufficientSpace if the workspace file system has less than bytes free.
+// Actions should call it before staging large inputs into the workspace.
+func (arb *ActionRunnerBase) EnsureFreeSpace(bytes uint64) error {
+	dir, err := arb.Workspace()
+	if err != nil {
+		return err
+	}
+	return checkFreeSpace(dir, bytes)
+}
+
+func (arb *ActionRunnerBase) setWorkspace(ws *actionWorkspace) {
+	arb.workspace = ws
+}
+
+// eventWorkspaceRoot is the directory holding the action workspaces of the current event
+func (pm *PluginManager) eventWorkspaceRoot() string {
+	root := localRootPath
+	if pm.ccStore != nil {
+		root = pm.ccStore.RootPath()
+	}
+	return filepath.Join(root, workspaceDir, workspaceName(pm.EventIdentifier))
+}
+
+// newWorkspace creates the (not yet materialized) workspace for an action in the current event
+func (pm *PluginManager) newWorkspace(action Action) *actionWorkspace {
+	return &actionWorkspace{
+		root:         pm.eventWorkspaceRoot(),
+		action:       workspaceName(action.Name),
+		minFreeBytes: pm.config.Workspace.MinFreeBytes,
+	}
+}
+
+// releaseWorkspace removes the workspace after the action completes unless the config keeps it.
+// The path of a kept workspace is returned.
+func (pm *PluginManager) releaseWorkspace(ws *actionWorkspace, failed bool) string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.dir == "" {
+		return ""
+	}
+	if (failed && pm.config.Workspace.KeepOnFailure) || (!failed && pm.config.Workspace.KeepOnSuccess) {
+		if failed {
+			pm.Logger.Warn("keeping workspace of failed action", "action", ws.action, "workspace", ws.dir)
+		}
+		return ws.dir
+	}
+	if err := os.RemoveAll(ws.dir); err != nil {
+		pm.Logger.Error("failed to remove action workspace", "action", ws.action, "workspace", ws.dir, "error", err.Error())
+		return ws.dir
+	}
+	ws.dir = ""
+	return ""
+}
+
+// removeEventWorkspaceRoot removes the workspace root of the current event once every workspace
+// in it has been removed.  A root holding kept workspaces is left in place.
+func (pm *PluginManager) removeEventWorkspaceRoot() {
+	root := pm.eventWorkspaceRoot()
+	entries, err := os.ReadDir(root)
+	if err != nil || len(entries) > 0 {
+		return
+	}
+	if err := os.Remove(root); err != nil {
+		pm.Logger.Error("failed to remove event workspace root", "workspace", root, "error", err.Error())
+	}
+}
+
+// current returns the workspace directory or an empty string if it has not been created
+func (ws *actionWorkspace) current() string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.dir
+}
+
+func (ws *actionWorkspace) path() (string, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.dir != "" {
+		return ws.dir, nil
+	}
+	if err := os.MkdirAll(ws.root, 0755); err != nil {
+		return "", fmt.Errorf("failed to create workspace root %s: %w", ws.root, err)
+	}
+	if err := checkFreeSpace(ws.root, ws.minFreeBytes); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(ws.root, ws.action+"-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create workspace for action %s: %w", ws.action, err)
+	}
+	ws.dir = dir
+	return dir, nil
+}
+
+func checkFreeSpace(path string, bytes uint64) error {
+	if bytes == 0 {
+		return nil
+	}
+	free, ok, err := freeSpace(path)
+	if err != nil {
+		return fmt.Errorf("failed to read free space of %s: %w", path, err)
+	}
+	if ok && free < bytes {
+		return fmt.Errorf("%w: %s has %d bytes free, %d required", ErrInsufficientSpace, path, free, bytes)
+	}
+	return nil
+}
+
+func workspaceName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "default"
+	}
+	return name
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin)
+
+package cc
+
+// freeSpace is not supported on this platform and the free space check is skipped
+func freeSpace(path string) (uint64, bool, error) {
+	return 0, false, nil
+}
+
+// usedSpace is not supported on this platform and file system usage is not monitored
+func usedSpace(path string) (uint64, bool, error) {
+	return 0, false, nil
+}
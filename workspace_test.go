@@ -0,0 +1,78 @@
+package cc
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type workspaceTestAction struct {
+	ActionRunnerBase
+	fail bool
+	dir  *string
+}
+
+func (a *workspaceTestAction) Run() error {
+	dir, err := a.Workspace()
+	if err != nil {
+		return err
+	}
+	*a.dir = dir
+	if err := os.WriteFile(filepath.Join(dir, "scratch.txt"), []byte("scratch"), 0644); err != nil {
+		return err
+	}
+	if a.fail {
+		return errors.New("model failed")
+	}
+	return nil
+}
+
+func TestActionWorkspace(t *testing.T) {
+	var dir string
+	for _, name := range []string{"workspace-success", "workspace-failure"} {
+		fail := name == "workspace-failure"
+		RegisterActionFactory(name, func(pm *PluginManager, action Action) (ActionRunner, error) {
+			return &workspaceTestAction{ActionRunnerBase{ActionName: action.Name, PluginManager: pm, Action: action}, fail, &dir}, nil
+		})
+	}
+	root := t.TempDir()
+	newManager := func(action string, config WorkspaceConfig) *PluginManager {
+		pm := newTestPluginManager(Action{Name: action})
+		pm.ccStore = &FSBCcStore{localRootPath: root, remoteRootPath: t.TempDir()}
+		pm.EventIdentifier = "3"
+		pm.config.Workspace = config
+		return pm
+	}
+
+	pm := newManager("workspace-success", WorkspaceConfig{})
+	if err := pm.RunActions(); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(dir) != filepath.Join(root, "workspaces", "3") {
+		t.Fatalf("unexpected workspace location %s", dir)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the workspace of a successful action to be removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(dir)); !os.IsNotExist(err) {
+		t.Fatalf("expected the empty event workspace root to be removed: %v", err)
+	}
+
+	pm = newManager("workspace-failure", WorkspaceConfig{KeepOnFailure: true})
+	if err := pm.RunActions(); err == nil {
+		t.Fatal("expected the action to fail")
+	}
+	if result := pm.RunSummary().Actions[0]; result.Workspace != dir {
+		t.Fatalf("expected the kept workspace %s in the result, found %q", dir, result.Workspace)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scratch.txt")); err != nil {
+		t.Fatalf("expected the workspace of a failed action to be kept: %v", err)
+	}
+
+	pm = newManager("workspace-success", WorkspaceConfig{MinFreeBytes: math.MaxUint64})
+	if err := pm.RunActions(); !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("expected an insufficient space error, found %v", err)
+	}
+}
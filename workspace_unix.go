@@ -0,0 +1,23 @@
+//go:build linux || darwin
+
+package cc
+
+import "syscall"
+
+// freeSpace returns the bytes available to unprivileged users on the file system containing path
+func freeSpace(path string) (uint64, bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), true, nil
+}
+
+// usedSpace returns the bytes in use on the file system containing path
+func usedSpace(path string) (uint64, bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false, err
+	}
+	return (stat.Blocks - stat.Bfree) * uint64(stat.Bsize), true, nil
+}